@@ -0,0 +1,46 @@
+package utils
+
+import "time"
+
+// Clock 时间来源，替换 time.Now、time.Sleep 与 time.NewTicker，单元测试中可以注入可控的时钟
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	Sleep(d time.Duration)
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 与 time.Ticker 一致
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock 系统时钟
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
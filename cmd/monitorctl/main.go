@@ -0,0 +1,259 @@
+// monitorctl 查看与操作 redis 中的 monitor group
+//
+//	monitorctl -addr 127.0.0.1:6379 -group order nodes
+//	monitorctl -addr 127.0.0.1:6379 -group order watches
+//	monitorctl -addr 127.0.0.1:6379 -group order context pay 1001
+//	monitorctl -addr 127.0.0.1:6379 -group order dump > order.json
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/FredyXue/go-utils/monitor"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const usage = `Usage: monitorctl [flags] <command> [args]
+
+Commands:
+  nodes                       节点列表与心跳时间
+  master                      当前 master
+  watches                     任务列表
+  context <key | method tag>  输出任务的上下文
+  reentry <key | method tag>  强制重入任务，由 master 在下一次心跳时重入
+  delete <key | method tag>   强制移除任务并清理上下文，不会触发重入
+  election                    强制重新选举
+  dump [file]                 导出任务列表、死信列表与上下文，默认输出到 stdout
+  restore <file>              导入 dump 的结果，file 为 - 时从 stdin 读取
+
+Flags:
+`
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:6379", "redis 地址，多个地址用逗号分隔时使用集群模式")
+	password := flag.String("password", "", "redis 密码")
+	db := flag.Int("db", 0, "redis db，集群模式下忽略")
+	group := flag.String("group", "", "monitor group，必填")
+	prefix := flag.String("prefix", "", "key 前缀，与 monitor.WithKeyPrefix 一致")
+	asJSON := flag.Bool("json", false, "以 JSON 输出")
+	timeout := flag.Duration("timeout", time.Second*30, "命令超时时间")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *group == "" || flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cli := redis.NewUniversalClient(&redis.UniversalOptions{
+		Addrs:    strings.Split(*addr, ","),
+		Password: *password,
+		DB:       *db,
+	})
+	defer cli.Close()
+	store := monitor.NewRedisStore(cli)
+	if *prefix != "" {
+		store = monitor.NewPrefixStore(store, *prefix)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	c := &ctl{admin: monitor.NewAdmin(store, *group), json: *asJSON, stdin: os.Stdin, stdout: os.Stdout}
+	if err := c.run(ctx, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type ctl struct {
+	admin  *monitor.Admin
+	json   bool
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// run 执行一条命令，args[0] 为命令名
+func (c *ctl) run(ctx context.Context, args []string) error {
+	cmd, args := args[0], args[1:]
+	switch cmd {
+	case "nodes":
+		return c.nodes(ctx)
+	case "master":
+		return c.master(ctx)
+	case "watches":
+		return c.watches(ctx)
+	case "context", "reentry", "delete":
+		key, err := c.key(args)
+		if err != nil {
+			return err
+		}
+		switch cmd {
+		case "context":
+			return c.context(ctx, key)
+		case "reentry":
+			return c.done(c.admin.ForceReentry(ctx, key), key)
+		default:
+			return c.done(c.admin.ForceUnwatch(ctx, key), key)
+		}
+	case "election":
+		return c.done(c.admin.ForceElection(ctx), "election")
+	case "dump":
+		return c.dump(ctx, args)
+	case "restore":
+		return c.restore(ctx, args)
+	}
+	return errors.Errorf("unknown command: %s", cmd)
+}
+
+// key 参数为完整的 key，或 method 与 tag
+func (c *ctl) key(args []string) (string, error) {
+	switch len(args) {
+	case 1:
+		return args[0], nil
+	case 2:
+		return c.admin.Key(args[0], args[1]), nil
+	}
+	return "", errors.New("key or method tag is required")
+}
+
+func (c *ctl) nodes(ctx context.Context) error {
+	list, err := c.admin.Nodes(ctx)
+	if err != nil || c.json {
+		return c.output(list, err)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "UID\tHEARTBEAT\tAGO\tMASTER")
+	for _, node := range list {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\n", node.UID, node.Heartbeat.Format(time.RFC3339), time.Since(node.Heartbeat).Truncate(time.Second), node.IsMaster)
+	}
+	return w.Flush()
+}
+
+func (c *ctl) master(ctx context.Context) error {
+	uid, err := c.admin.Master(ctx)
+	if err != nil || c.json {
+		return c.output(map[string]string{"uid": uid}, err)
+	}
+	if uid == "" {
+		uid = "(none)"
+	}
+	_, err = fmt.Fprintln(c.stdout, uid)
+	return err
+}
+
+func (c *ctl) watches(ctx context.Context) error {
+	list, err := c.admin.Watches(ctx)
+	if err != nil || c.json {
+		return c.output(list, err)
+	}
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "METHOD\tTAG\tOWNER\tAGE\tSIZE\tTTL")
+	for _, info := range list {
+		owner, age, ttl := info.Owner, "-", info.ContextTTL.Truncate(time.Second).String()
+		if owner == "" {
+			owner = "(reentry)"
+		}
+		if !info.StartAt.IsZero() {
+			age = info.Age.Truncate(time.Second).String()
+		}
+		switch info.ContextTTL {
+		case -1:
+			ttl = "never"
+		case -2:
+			ttl = "missing"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", info.Method, info.Tag, owner, age, info.ContextSize, ttl)
+	}
+	return w.Flush()
+}
+
+// context 原样输出上下文
+func (c *ctl) context(ctx context.Context, key string) error {
+	body, err := c.admin.Context(ctx, key)
+	if errors.Is(err, monitor.ErrNil) {
+		return errors.Errorf("context not found, key: %s", key)
+	}
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.output(map[string]string{"key": key, "context": string(body)}, nil)
+	}
+	_, err = fmt.Fprintln(c.stdout, string(body))
+	return err
+}
+
+func (c *ctl) dump(ctx context.Context, args []string) error {
+	dump, err := c.admin.Dump(ctx)
+	if err != nil {
+		return err
+	}
+	w := c.stdout
+	if len(args) > 0 && args[0] != "-" {
+		f, err := os.Create(args[0])
+		if err != nil {
+			return errors.Wrap(err, "create dump file")
+		}
+		defer f.Close()
+		w = f
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dump)
+}
+
+func (c *ctl) restore(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("dump file is required")
+	}
+	r := c.stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return errors.Wrap(err, "open dump file")
+		}
+		defer f.Close()
+		r = f
+	}
+	dump := &monitor.GroupDump{}
+	if err := json.NewDecoder(r).Decode(dump); err != nil {
+		return errors.Wrap(err, "decode dump file")
+	}
+	n, err := c.admin.Restore(ctx, dump)
+	if err != nil {
+		return err
+	}
+	return c.done(nil, fmt.Sprintf("restored %d of %d tasks", n, len(dump.Tasks)))
+}
+
+// done 写操作成功后输出 ok
+func (c *ctl) done(err error, msg string) error {
+	if err != nil {
+		return err
+	}
+	if c.json {
+		return c.output(map[string]string{"result": msg}, nil)
+	}
+	_, err = fmt.Fprintln(c.stdout, "ok:", msg)
+	return err
+}
+
+func (c *ctl) output(v any, err error) error {
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FredyXue/go-utils/monitor"
+	"github.com/FredyXue/go-utils/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCtl(t *testing.T) {
+	store := monitor.NewRedisStore(testdata.NewTestRedis())
+	group := "test_monitorctl"
+	ctx := context.TODO()
+	out := &bytes.Buffer{}
+	c := &ctl{admin: monitor.NewAdmin(store, group), stdout: out}
+	run := func(args ...string) (string, error) {
+		out.Reset()
+		err := c.run(ctx, args)
+		return out.String(), err
+	}
+
+	key := c.admin.Key("test_method", "1")
+	assert.NoError(t, store.Heartbeat(ctx, group, "node1", time.Now().Unix()))
+	assert.NoError(t, store.SetMaster(ctx, group, "node1", time.Minute))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body1"), time.Minute))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "node1", time.Now().Unix()))
+
+	s, err := run("nodes")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Contains(s, "node1") && strings.Contains(s, "true"), true, s)
+	s, err = run("master")
+	assert.Equal(t, []any{s, err}, []any{"node1\n", nil})
+	s, err = run("watches")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Contains(s, "test_method  1    node1"), true, s)
+	s, err = run("context", "test_method", "1")
+	assert.Equal(t, []any{s, err}, []any{"body1\n", nil})
+	_, err = run("context", "test_method", "2")
+	assert.Error(t, err)
+
+	// dump 后删除，再 restore
+	dump, err := run("dump")
+	assert.NoError(t, err)
+	s, err = run("delete", key)
+	assert.Equal(t, []any{s, err}, []any{"ok: " + key + "\n", nil})
+	_, err = run("delete", key)
+	assert.ErrorIs(t, err, monitor.ErrWatchNotFound)
+	c.stdin = strings.NewReader(dump)
+	s, err = run("restore", "-")
+	assert.Equal(t, []any{s, err}, []any{"ok: restored 1 of 1 tasks\n", nil})
+	s, err = run("context", key)
+	assert.Equal(t, []any{s, err}, []any{"body1\n", nil})
+
+	s, err = run("reentry", key)
+	assert.Equal(t, []any{s, err}, []any{"ok: " + key + "\n", nil})
+	c.json = true
+	s, err = run("watches")
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Contains(s, `"owner": ""`), true, s)
+
+	_, err = run("unknown")
+	assert.Error(t, err)
+}
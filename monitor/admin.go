@@ -0,0 +1,346 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWatchNotFound 任务不在任务列表中
+var ErrWatchNotFound = errors.New("[Monitor] watch not found")
+
+// Admin 查看与操作一个 monitor group，不需要启动 Monitor
+type Admin struct {
+	store Store
+	group string
+}
+
+// NodeInfo 节点信息
+type NodeInfo struct {
+	UID       string    `json:"uid"`
+	Heartbeat time.Time `json:"heartbeat"` // 最近一次心跳时间
+	IsMaster  bool      `json:"is_master"`
+}
+
+// WatchInfo 任务信息
+type WatchInfo struct {
+	Key         string        `json:"key"` // group|method|tag
+	Method      string        `json:"method"`
+	Tag         string        `json:"tag"`
+	Owner       string        `json:"owner"`        // 任务所属节点，空表示等待 master 重入
+	StartAt     time.Time     `json:"start_at"`     // 首次 watch 时间
+	Age         time.Duration `json:"age"`          // 距离首次 watch 的时长
+	ContextSize int           `json:"context_size"` // 上下文字节数，-1 表示上下文不存在
+	ContextTTL  time.Duration `json:"context_ttl"`  // 上下文剩余时长。-1 永不过期，-2 不存在
+}
+
+// GroupDump group 的状态快照，包括任务列表、死信列表及其上下文，不包括节点与 master
+type GroupDump struct {
+	Group string     `json:"group"`
+	Time  time.Time  `json:"time"`
+	Tasks []TaskDump `json:"tasks"` // 按 key 排序
+}
+
+// TaskDump 任务快照
+type TaskDump struct {
+	Key        string            `json:"key"`                   // group|method|tag
+	Owner      string            `json:"owner,omitempty"`       // 任务所属节点，任务在任务列表中时有效
+	DeadLetter []byte            `json:"dead_letter,omitempty"` // 死信，非空表示任务在死信列表中
+	Context    []byte            `json:"context"`               // 上下文，nil 表示上下文不存在
+	TTL        time.Duration     `json:"ttl"`                   // 上下文剩余时长，-1 永不过期
+	Steps      map[string][]byte `json:"steps,omitempty"`       // 步骤
+}
+
+func NewAdmin(store Store, group string) *Admin {
+	return &Admin{store: store, group: group}
+}
+
+// Key 任务 key，group|method|tag
+func (a *Admin) Key(method string, tag string) string {
+	return BuildKey(a.group, method, tag)
+}
+
+// Nodes 节点列表，按 uid 排序
+func (a *Admin) Nodes(ctx context.Context) (list []NodeInfo, err error) {
+	nodes, err := a.store.NodeList(ctx, a.group)
+	if err != nil {
+		err = errors.Wrap(err, "[Admin] Nodes Error")
+		return
+	}
+	master, err := a.Master(ctx)
+	if err != nil {
+		return
+	}
+	list = make([]NodeInfo, 0, len(nodes))
+	for uid, timestamp := range nodes {
+		list = append(list, NodeInfo{UID: uid, Heartbeat: time.Unix(timestamp, 0), IsMaster: uid == master})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UID < list[j].UID })
+	return
+}
+
+// Master 当前 master 的 uid，没有 master 时返回空
+func (a *Admin) Master(ctx context.Context) (uid string, err error) {
+	uid, err = a.store.GetMaster(ctx, a.group)
+	if errors.Is(err, ErrNil) {
+		return "", nil
+	}
+	err = errors.Wrap(err, "[Admin] Master Error")
+	return
+}
+
+// Watches 任务列表，按 key 排序
+func (a *Admin) Watches(ctx context.Context) (list []WatchInfo, err error) {
+	maps, err := a.store.WatchList(ctx, a.group)
+	if err != nil {
+		err = errors.Wrap(err, "[Admin] Watches Error")
+		return
+	}
+	times, err := a.store.WatchTimes(ctx, a.group)
+	if err != nil {
+		err = errors.Wrap(err, "[Admin] Watches Error")
+		return
+	}
+
+	list = make([]WatchInfo, 0, len(maps))
+	for key, uid := range maps {
+		info := WatchInfo{Key: key, Owner: uid, ContextSize: -1}
+		_, info.Method, info.Tag, _ = ParseKey(key)
+		if timestamp, has := times[key]; has {
+			info.StartAt = time.Unix(timestamp, 0)
+			info.Age = time.Since(info.StartAt)
+		}
+
+		body, err := a.store.GetContext(ctx, key)
+		if err != nil && !errors.Is(err, ErrNil) {
+			return nil, errors.Wrap(err, "[Admin] Watches GetContext Error")
+		}
+		if err == nil {
+			info.ContextSize = len(body)
+		}
+		if info.ContextTTL, err = a.store.ContextTTL(ctx, key); err != nil {
+			return nil, errors.Wrap(err, "[Admin] Watches ContextTTL Error")
+		}
+		list = append(list, info)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return
+}
+
+// Context 任务的上下文，不存在时返回 ErrNil
+func (a *Admin) Context(ctx context.Context, key string) ([]byte, error) {
+	body, err := a.store.GetContext(ctx, key)
+	return body, errors.Wrap(err, "[Admin] Context Error")
+}
+
+// Dump 导出任务列表与死信列表，以及各自的上下文与步骤
+// 导出期间 group 仍在运行，快照不保证一致
+func (a *Admin) Dump(ctx context.Context) (dump *GroupDump, err error) {
+	maps, err := a.store.WatchList(ctx, a.group)
+	if err != nil {
+		return nil, errors.Wrap(err, "[Admin] Dump WatchList Error")
+	}
+	letters, err := a.store.DeadLetters(ctx, a.group)
+	if err != nil {
+		return nil, errors.Wrap(err, "[Admin] Dump DeadLetters Error")
+	}
+
+	dump = &GroupDump{Group: a.group, Time: time.Now(), Tasks: make([]TaskDump, 0, len(maps)+len(letters))}
+	add := func(task TaskDump) error {
+		body, err := a.store.GetContext(ctx, task.Key)
+		if err != nil && !errors.Is(err, ErrNil) {
+			return errors.Wrap(err, "[Admin] Dump GetContext Error")
+		}
+		task.Context = body
+		if task.TTL, err = a.store.ContextTTL(ctx, task.Key); err != nil {
+			return errors.Wrap(err, "[Admin] Dump ContextTTL Error")
+		}
+		if task.Steps, err = a.store.GetSteps(ctx, task.Key); err != nil {
+			return errors.Wrap(err, "[Admin] Dump GetSteps Error")
+		}
+		dump.Tasks = append(dump.Tasks, task)
+		return nil
+	}
+	for key, uid := range maps {
+		if err = add(TaskDump{Key: key, Owner: uid}); err != nil {
+			return nil, err
+		}
+	}
+	for key, body := range letters {
+		if err = add(TaskDump{Key: key, DeadLetter: body}); err != nil {
+			return nil, err
+		}
+	}
+	sort.Slice(dump.Tasks, func(i, j int) bool { return dump.Tasks[i].Key < dump.Tasks[j].Key })
+	return
+}
+
+// Restore 导入 Dump 的快照，返回导入的任务数量
+// 快照来自其他 group 时，任务 key 改写为当前 group。已在任务列表或死信列表中的任务跳过，不覆盖上下文。
+// 任务保留原节点，原节点不在线时由 master 重入
+func (a *Admin) Restore(ctx context.Context, dump *GroupDump) (n int, err error) {
+	maps, err := a.store.WatchList(ctx, a.group)
+	if err != nil {
+		return 0, errors.Wrap(err, "[Admin] Restore WatchList Error")
+	}
+	letters, err := a.store.DeadLetters(ctx, a.group)
+	if err != nil {
+		return 0, errors.Wrap(err, "[Admin] Restore DeadLetters Error")
+	}
+
+	for _, task := range dump.Tasks {
+		_, method, tag, ok := ParseKey(task.Key)
+		if !ok {
+			return n, errors.Errorf("[Admin] Restore Error: invalid key %s", task.Key)
+		}
+		key := BuildKey(a.group, method, tag)
+		if _, has := maps[key]; has {
+			continue
+		}
+		if _, has := letters[key]; has {
+			continue
+		}
+
+		// 先写入上下文，避免 master 将没有上下文的任务视为无效任务
+		var expiration time.Duration
+		if task.TTL > 0 {
+			expiration = task.TTL
+		}
+		if task.Context != nil {
+			if err = a.store.SetContext(ctx, key, task.Context, expiration); err != nil {
+				return n, errors.Wrap(err, "[Admin] Restore SetContext Error")
+			}
+		}
+		for step, body := range task.Steps {
+			if err = a.store.SetStep(ctx, key, step, body, expiration); err != nil {
+				return n, errors.Wrap(err, "[Admin] Restore SetStep Error")
+			}
+		}
+		if len(task.DeadLetter) > 0 {
+			err = a.store.AddDeadLetter(ctx, a.group, key, task.DeadLetter)
+		} else {
+			err = a.store.AddWatch(ctx, a.group, key, task.Owner, time.Now().Unix())
+		}
+		if err != nil {
+			return n, errors.Wrap(err, "[Admin] Restore Error")
+		}
+		n++
+	}
+	return
+}
+
+// ForceReentry 强制重入任务
+// 任务被标记为无主任务，由 master 在下一次心跳时重入。原节点如果仍在执行，不会被中断，之后的 Unwatch 不会影响新的执行
+// 原节点为 master 时，master 放弃本地的任务并重新执行
+func (a *Admin) ForceReentry(ctx context.Context, key string) error {
+	if err := a.checkWatch(ctx, key); err != nil {
+		return err
+	}
+	err := a.store.AddWatch(ctx, a.group, key, orphanUID, time.Now().Unix())
+	return errors.Wrap(err, "[Admin] ForceReentry Error")
+}
+
+// ForceUnwatch 强制移除卡住的任务，同时清理上下文，不会触发重入
+func (a *Admin) ForceUnwatch(ctx context.Context, key string) error {
+	if err := a.checkWatch(ctx, key); err != nil {
+		return err
+	}
+	if err := a.store.RemoveWatch(ctx, a.group, key); err != nil {
+		return errors.Wrap(err, "[Admin] ForceUnwatch Error")
+	}
+	err := a.store.DelContext(ctx, key)
+	return errors.Wrap(err, "[Admin] ForceUnwatch Error")
+}
+
+// ForceElection 强制重新选举
+// 删除选举锁，当前 master 在下一次心跳时降级，所有节点重新抢占
+func (a *Admin) ForceElection(ctx context.Context) error {
+	_, err := a.store.NewLocker().UnlockForce(a.group)
+	return errors.Wrap(err, "[Admin] ForceElection Error")
+}
+
+// checkWatch 检查任务是否存在
+func (a *Admin) checkWatch(ctx context.Context, key string) error {
+	maps, err := a.store.WatchList(ctx, a.group)
+	if err != nil {
+		return errors.Wrap(err, "[Admin] WatchList Error")
+	}
+	if _, has := maps[key]; !has {
+		return errors.Wrapf(ErrWatchNotFound, "key: %s", key)
+	}
+	return nil
+}
+
+// ServeHTTP 以 JSON 输出，可以挂载在任意前缀下，按路径最后一段路由
+//
+//	GET  nodes              节点列表
+//	GET  master             当前 master
+//	GET  watches            任务列表
+//	POST reentry?key=xxx    强制重入任务
+//	POST unwatch?key=xxx    强制移除任务
+//	POST election           强制重新选举
+//
+// http.Handle("/monitor/", http.StripPrefix("/monitor", NewAdmin(store, group)))
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	action := path.Base(r.URL.Path)
+
+	var resp any
+	var err error
+	switch action {
+	case "nodes", "master", "watches":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+	case "reentry", "unwatch", "election":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if action != "election" && r.URL.Query().Get("key") == "" {
+			http.Error(w, "key is required", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	key := r.URL.Query().Get("key")
+	switch action {
+	case "nodes":
+		resp, err = a.Nodes(ctx)
+	case "master":
+		var uid string
+		uid, err = a.Master(ctx)
+		resp = map[string]string{"uid": uid}
+	case "watches":
+		resp, err = a.Watches(ctx)
+	case "reentry":
+		err = a.ForceReentry(ctx, key)
+		resp = map[string]string{"key": key}
+	case "unwatch":
+		err = a.ForceUnwatch(ctx, key)
+		resp = map[string]string{"key": key}
+	case "election":
+		err = a.ForceElection(ctx)
+		resp = map[string]string{"group": a.group}
+	}
+
+	if errors.Is(err, ErrWatchNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,199 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_admin"
+	ctx := context.TODO()
+	done := make(chan string, 10)
+	recorder := &eventRecorder{}
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+		WithEventFunc(recorder.record),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {
+		body, _ := mctx.Get()
+		done <- string(body)
+	})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	uid := m1.(*monitorImpl).uid
+
+	admin := NewAdmin(store, group)
+	server := httptest.NewServer(http.StripPrefix("/monitor", admin))
+	defer server.Close()
+	request := func(method string, path string, resp any) int {
+		req, _ := http.NewRequest(method, server.URL+"/monitor/"+path, nil)
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer res.Body.Close()
+		if resp != nil && res.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(res.Body).Decode(resp))
+		}
+		return res.StatusCode
+	}
+
+	// 节点与 master
+	var nodes []NodeInfo
+	assert.Equal(t, request("GET", "nodes", &nodes), http.StatusOK)
+	assert.Equal(t, []any{len(nodes), nodes[0].UID, nodes[0].IsMaster}, []any{1, uid, true})
+	var master map[string]string
+	assert.Equal(t, request("GET", "master", &master), http.StatusOK)
+	assert.Equal(t, master["uid"], uid)
+
+	// 任务列表
+	_, err := m1.Watch("test_method", "1", []byte("body1"))
+	assert.NoError(t, err)
+	_, err = m1.Watch("test_method", "2", []byte("body_2"))
+	assert.NoError(t, err)
+	var watches []WatchInfo
+	assert.Equal(t, request("GET", "watches", &watches), http.StatusOK)
+	assert.Equal(t, len(watches), 2)
+	assert.Equal(t, []any{watches[0].Key, watches[0].Tag, watches[0].Owner, watches[0].ContextSize, watches[0].StartAt.IsZero()},
+		[]any{group + "|test_method|1", "1", uid, 5, false})
+
+	// 强制重入：其他节点卡住的任务
+	key := group + "|test_method|3"
+	assert.NoError(t, store.Heartbeat(ctx, group, "stuck", time.Now().Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "stuck", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body3"), time.Minute))
+	assert.Equal(t, request("POST", "reentry?key="+key, nil), http.StatusOK)
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "body3")
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+
+	// 强制移除
+	assert.Equal(t, request("POST", "unwatch?key="+group+"|test_method|2", nil), http.StatusOK)
+	_, err = store.GetContext(ctx, group+"|test_method|2")
+	assert.ErrorIs(t, err, ErrNil)
+	assert.Equal(t, request("POST", "unwatch?key="+group+"|test_method|2", nil), http.StatusNotFound)
+	assert.Equal(t, request("POST", "unwatch", nil), http.StatusBadRequest)
+	assert.Equal(t, request("GET", "unwatch?key=x", nil), http.StatusMethodNotAllowed)
+	assert.Equal(t, request("GET", "unknown", nil), http.StatusNotFound)
+
+	// 强制重新选举：m1 先降级，再重新当选
+	assert.Equal(t, request("POST", "election", nil), http.StatusOK)
+	assert.Eventually(t, func() bool {
+		recorder.mu.Lock()
+		defer recorder.mu.Unlock()
+		lost := false
+		for _, e := range recorder.events {
+			switch {
+			case e.Type == EventMasterLost:
+				lost = true
+			case e.Type == EventMasterElected && lost:
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, m1.IsMaster(), true)
+	e, err := admin.Master(ctx)
+	assert.Equal(t, []any{e, err}, []any{uid, nil})
+}
+
+// 测试导出后导入到另一个 group
+func TestAdminDump(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_admin_dump"
+	ctx := context.TODO()
+
+	admin := NewAdmin(store, group)
+	key1, key2, key3 := admin.Key("test_method", "1"), admin.Key("test_method", "2"), admin.Key("test_method", "3")
+	assert.NoError(t, store.SetContext(ctx, key1, []byte("body1"), time.Minute))
+	assert.NoError(t, store.SetStep(ctx, key1, "step1", []byte("s1"), time.Minute))
+	assert.NoError(t, store.AddWatch(ctx, group, key1, "node1", time.Now().Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key2, "node2", time.Now().Unix())) // 上下文不存在
+	assert.NoError(t, store.SetContext(ctx, key3, []byte("body3"), 0))
+	assert.NoError(t, store.AddDeadLetter(ctx, group, key3, []byte(`{"attempts":3}`)))
+
+	body, err := admin.Context(ctx, key1)
+	assert.Equal(t, []any{string(body), err}, []any{"body1", nil})
+	_, err = admin.Context(ctx, admin.Key("test_method", "4"))
+	assert.ErrorIs(t, err, ErrNil)
+
+	dump, err := admin.Dump(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, len(dump.Tasks), 3)
+	assert.Equal(t, []any{dump.Tasks[0].Key, dump.Tasks[0].Owner, string(dump.Tasks[0].Context), string(dump.Tasks[0].Steps["step1"])},
+		[]any{key1, "node1", "body1", "s1"})
+	assert.Equal(t, []any{dump.Tasks[1].Context == nil, dump.Tasks[1].TTL}, []any{true, time.Duration(-2)})
+	assert.Equal(t, []any{string(dump.Tasks[2].DeadLetter), dump.Tasks[2].TTL}, []any{`{"attempts":3}`, time.Duration(-1)})
+
+	// JSON 往返后导入到新的 group
+	data, err := json.Marshal(dump)
+	assert.NoError(t, err)
+	restored := &GroupDump{}
+	assert.NoError(t, json.Unmarshal(data, restored))
+	target := NewAdmin(store, group+"_restore")
+	n, err := target.Restore(ctx, restored)
+	assert.Equal(t, []any{n, err}, []any{3, nil})
+	watches, err := target.Watches(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, len(watches), 2)
+	assert.Equal(t, []any{watches[0].Key, watches[0].Owner, watches[0].ContextSize, watches[0].ContextTTL > 0},
+		[]any{target.Key("test_method", "1"), "node1", 5, true})
+	steps, _ := store.GetSteps(ctx, target.Key("test_method", "1"))
+	assert.Equal(t, string(steps["step1"]), "s1")
+	letters, _ := store.DeadLetters(ctx, group+"_restore")
+	assert.Equal(t, string(letters[target.Key("test_method", "3")]), `{"attempts":3}`)
+
+	// 已存在的任务跳过
+	n, err = target.Restore(ctx, restored)
+	assert.Equal(t, []any{n, err}, []any{0, nil})
+}
+
+// 测试强制重入 master 本地执行中的任务
+func TestAdminForceReentryMaster(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_admin_master"
+	ctx := context.TODO()
+	done := make(chan string, 1)
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {
+		body, _ := mctx.Get()
+		done <- string(body)
+	})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	assert.Equal(t, m1.IsMaster(), true)
+
+	// master 上卡住的任务
+	_, err := m1.Watch("test_method", "1", []byte("body1"))
+	assert.NoError(t, err)
+	admin := NewAdmin(store, group)
+	assert.NoError(t, admin.ForceReentry(ctx, admin.Key("test_method", "1")))
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "body1")
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+	assert.Eventually(t, func() bool {
+		list, err := m1.WatchList()
+		return err == nil && len(list) == 0
+	}, time.Second, time.Millisecond*10)
+}
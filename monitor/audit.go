@@ -0,0 +1,278 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// AuditAction 审计记录的动作
+type AuditAction string
+
+const (
+	AuditWatch   AuditAction = "watch"   // 任务开始监控
+	AuditSet     AuditAction = "set"     // 更新上下文，Size 为上下文大小
+	AuditUnwatch AuditAction = "unwatch" // 解除监控，Cost 为本节点上的执行时长；被 Cancel 时 Err 为 ErrCanceled
+	AuditReentry AuditAction = "reentry" // 开始重入，Owner 为原节点，Attempts 为重入次数
+	AuditExpire  AuditAction = "expire"  // 无效任务被 master 移除
+)
+
+// AuditRecord 任务生命周期的审计记录
+type AuditRecord struct {
+	ID       string        `json:"id"` // 存储中的 id，写入时忽略
+	Action   AuditAction   `json:"action"`
+	Key      string        `json:"key"` // group|method|tag
+	Method   string        `json:"method"`
+	Tag      string        `json:"tag"`
+	UID      string        `json:"uid"`   // 产生记录的节点
+	Owner    string        `json:"owner"` // 任务原来所属的节点
+	Time     time.Time     `json:"time"`
+	Cost     time.Duration `json:"cost"`
+	Size     int           `json:"size"`
+	Attempts int64         `json:"attempts"`
+	Err      string        `json:"err"`
+}
+
+// AuditQuery 查询条件，零值表示不限制
+type AuditQuery struct {
+	Method string
+	Tag    string
+	Start  time.Time // 包含
+	End    time.Time // 包含
+	Limit  int       // 最多返回的条数，默认 100
+}
+
+// defaultAuditLimit AuditQuery.Limit 的默认值
+const defaultAuditLimit = 100
+
+func (q AuditQuery) limit() int {
+	if q.Limit > 0 {
+		return q.Limit
+	}
+	return defaultAuditLimit
+}
+
+// match 是否满足 method、tag 与时间范围
+func (q AuditQuery) match(r AuditRecord) bool {
+	if q.Method != "" && q.Method != r.Method {
+		return false
+	}
+	if q.Tag != "" && q.Tag != r.Tag {
+		return false
+	}
+	if !q.Start.IsZero() && r.Time.Before(q.Start) {
+		return false
+	}
+	if !q.End.IsZero() && r.Time.After(q.End) {
+		return false
+	}
+	return true
+}
+
+// AuditSink 审计记录的存储，按 group 分开保存，超过保留上限的旧记录被丢弃
+type AuditSink interface {
+	Append(ctx context.Context, group string, record AuditRecord) error
+	Query(ctx context.Context, group string, query AuditQuery) ([]AuditRecord, error) // 满足条件的最近 Limit 条，按时间顺序返回
+}
+
+// WithAuditSink 记录任务的 Watch、Set、Unwatch、Reentry、Expire，默认不记录
+// 写入在调用方的 goroutine 中同步执行，失败时只输出日志，不影响任务
+func WithAuditSink(sink AuditSink) MOpt {
+	return func(r *monitorImpl) {
+		r.auditSink = sink
+	}
+}
+
+// audit 写入审计记录，未配置 AuditSink 时不做任何操作
+func (m *monitorImpl) audit(ctx context.Context, r AuditRecord) {
+	if m.auditSink == nil {
+		return
+	}
+	r.UID = m.uid
+	r.Time = m.clock.Now()
+	_, r.Method, r.Tag, _ = ParseKey(r.Key)
+	if err := m.auditSink.Append(ctx, m.keyPrefix+m.group, r); err != nil {
+		log.Printf("[Monitor] audit Append action: %s, key: %s, Error: %v", r.Action, r.Key, err)
+	}
+}
+
+// redisAuditSink 每个 group 一个 redis stream {group}:Audit
+// 字段 action、key、uid、owner、time、cost、size、attempts、err，时间均为 ms
+type redisAuditSink struct {
+	cli    redis.UniversalClient
+	maxLen int64
+	maxAge time.Duration
+}
+
+// NewRedisAuditSink 使用 redis stream 保存审计记录
+// maxLen 每个 group 保留的最大条数，近似裁剪；maxAge 保留时长，需要 redis 6.2 以上，设置后 maxLen 不生效；均为 0 时不裁剪
+func NewRedisAuditSink(cli redis.UniversalClient, maxLen int64, maxAge time.Duration) AuditSink {
+	return &redisAuditSink{cli: cli, maxLen: maxLen, maxAge: maxAge}
+}
+
+func (s *redisAuditSink) stream(group string) string {
+	return "{" + group + "}:Audit"
+}
+
+func (s *redisAuditSink) Append(ctx context.Context, group string, r AuditRecord) error {
+	values := map[string]interface{}{
+		"action": string(r.Action),
+		"key":    r.Key,
+		"uid":    r.UID,
+		"time":   r.Time.UnixMilli(),
+	}
+	if r.Owner != "" {
+		values["owner"] = r.Owner
+	}
+	if r.Cost > 0 {
+		values["cost"] = r.Cost.Milliseconds()
+	}
+	if r.Size > 0 {
+		values["size"] = r.Size
+	}
+	if r.Attempts > 0 {
+		values["attempts"] = r.Attempts
+	}
+	if r.Err != "" {
+		values["err"] = r.Err
+	}
+	args := &redis.XAddArgs{Stream: s.stream(group), MaxLen: s.maxLen, Approx: true, Values: values}
+	if s.maxAge > 0 {
+		args.MinID = strconv.FormatInt(r.Time.Add(-s.maxAge).UnixMilli(), 10)
+		args.MaxLen = 0 // MAXLEN 与 MINID 不能同时使用，按时长裁剪
+	}
+	err := s.cli.XAdd(ctx, args).Err()
+	return errors.Wrap(err, "[RedisAuditSink] Append XAdd Error")
+}
+
+// Query 从最新的记录开始分批 XREVRANGE，时间范围按 stream id 过滤，即 redis 写入时间
+func (s *redisAuditSink) Query(ctx context.Context, group string, q AuditQuery) ([]AuditRecord, error) {
+	start, end := "-", "+"
+	if !q.Start.IsZero() {
+		start = strconv.FormatInt(q.Start.UnixMilli(), 10)
+	}
+	if !q.End.IsZero() {
+		end = strconv.FormatInt(q.End.UnixMilli(), 10)
+	}
+	q.Start, q.End = time.Time{}, time.Time{}
+
+	const batch = 500
+	list := make([]AuditRecord, 0)
+	for len(list) < q.limit() {
+		msgs, err := s.cli.XRevRangeN(ctx, s.stream(group), end, start, batch).Result()
+		if err != nil {
+			return nil, errors.Wrap(err, "[RedisAuditSink] Query XRevRange Error")
+		}
+		for _, msg := range msgs {
+			if r := parseAuditMessage(msg); q.match(r) {
+				if list = append(list, r); len(list) >= q.limit() {
+					break
+				}
+			}
+		}
+		if len(msgs) < batch {
+			break
+		}
+		if end = prevStreamID(msgs[len(msgs)-1].ID); end == "" { // 从上一批之前继续
+			break
+		}
+	}
+	reverseAudit(list)
+	return list, nil
+}
+
+// prevStreamID 小于 id 的最大 stream id，已是最小 id 时返回空
+// 不使用排他区间 (id，兼容 redis 6.2 以下版本
+func prevStreamID(id string) string {
+	msStr, seqStr, _ := strings.Cut(id, "-")
+	ms, err1 := strconv.ParseUint(msStr, 10, 64)
+	seq, err2 := strconv.ParseUint(seqStr, 10, 64)
+	switch {
+	case err1 != nil || err2 != nil:
+		return ""
+	case seq > 0:
+		return strconv.FormatUint(ms, 10) + "-" + strconv.FormatUint(seq-1, 10)
+	case ms > 0:
+		return strconv.FormatUint(ms-1, 10) + "-" + strconv.FormatUint(math.MaxUint64, 10)
+	}
+	return ""
+}
+
+// reverseAudit 从新到旧的记录转为按时间顺序
+func reverseAudit(list []AuditRecord) {
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+}
+
+func parseAuditMessage(msg redis.XMessage) AuditRecord {
+	str := func(field string) string {
+		v, _ := msg.Values[field].(string)
+		return v
+	}
+	num := func(field string) int64 {
+		v, _ := strconv.ParseInt(str(field), 10, 64)
+		return v
+	}
+	r := AuditRecord{
+		ID:       msg.ID,
+		Action:   AuditAction(str("action")),
+		Key:      str("key"),
+		UID:      str("uid"),
+		Owner:    str("owner"),
+		Time:     time.UnixMilli(num("time")),
+		Cost:     time.Duration(num("cost")) * time.Millisecond,
+		Size:     int(num("size")),
+		Attempts: num("attempts"),
+		Err:      str("err"),
+	}
+	_, r.Method, r.Tag, _ = ParseKey(r.Key)
+	return r
+}
+
+// memoryAuditSink 进程内的审计存储，适用于单进程工具与单元测试
+type memoryAuditSink struct {
+	mu      sync.Mutex
+	maxLen  int
+	seq     int64
+	records map[string][]AuditRecord // group -> 按写入顺序
+}
+
+// NewMemoryAuditSink maxLen 每个 group 保留的最大条数，0 表示不限制
+func NewMemoryAuditSink(maxLen int) AuditSink {
+	return &memoryAuditSink{maxLen: maxLen, records: make(map[string][]AuditRecord)}
+}
+
+func (s *memoryAuditSink) Append(ctx context.Context, group string, r AuditRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	r.ID = strconv.FormatInt(s.seq, 10)
+	records := append(s.records[group], r)
+	if s.maxLen > 0 && len(records) > s.maxLen {
+		records = append([]AuditRecord(nil), records[len(records)-s.maxLen:]...)
+	}
+	s.records[group] = records
+	return nil
+}
+
+func (s *memoryAuditSink) Query(ctx context.Context, group string, q AuditQuery) ([]AuditRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := s.records[group]
+	list := make([]AuditRecord, 0)
+	for i := len(records) - 1; i >= 0 && len(list) < q.limit(); i-- {
+		if q.match(records[i]) {
+			list = append(list, records[i])
+		}
+	}
+	reverseAudit(list)
+	return list, nil
+}
@@ -0,0 +1,262 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// miniredis v2.5 不支持 stream，这里使用 memory 实现测试审计记录，redis 实现见 TestRedisAuditSink
+func TestMonitorAudit(t *testing.T) {
+	store := NewMemoryStore()
+	sink := NewMemoryAuditSink(0)
+	group := "test_monitor_audit"
+	ctx := context.TODO()
+	done := make(chan struct{}, 1)
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithAuditSink(sink),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {
+		done <- struct{}{}
+	})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	start := time.Now()
+	mctx, err := m1.Watch("test_method", "tag1", []byte("data"))
+	assert.NoError(t, err)
+	assert.NoError(t, mctx.Set([]byte("data2")))
+	assert.NoError(t, m1.Unwatch("test_method", "tag1"))
+
+	// 其他节点遗留的任务被重入
+	key := BuildKey(group, "test_method", "tag2")
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+
+	list, err := sink.Query(ctx, group, AuditQuery{Method: "test_method", Tag: "tag1"})
+	assert.NoError(t, err)
+	var actions []AuditAction
+	for _, r := range list {
+		actions = append(actions, r.Action)
+		assert.Equal(t, []any{r.Key, r.Method, r.UID}, []any{BuildKey(group, "test_method", "tag1"), "test_method", m1.(*monitorImpl).uid})
+	}
+	assert.Equal(t, actions, []AuditAction{AuditWatch, AuditSet, AuditSet, AuditUnwatch})
+	assert.Equal(t, list[2].Size, len("data2"))
+	assert.Equal(t, list[3].Cost > 0, true)
+
+	list, err = sink.Query(ctx, group, AuditQuery{Tag: "tag2"})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list) > 0, true)
+	assert.Equal(t, []any{list[0].Action, list[0].Owner, list[0].Attempts}, []any{AuditReentry, "ghost", int64(1)})
+
+	// 时间范围
+	list, err = sink.Query(ctx, group, AuditQuery{End: start.Add(-time.Millisecond)})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list), 0)
+	list, err = sink.Query(ctx, group, AuditQuery{Start: start, Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list), 2)
+}
+
+// 测试无效任务被移除与保留上限
+func TestMonitorAuditExpire(t *testing.T) {
+	store := NewMemoryStore()
+	sink := NewMemoryAuditSink(2)
+	group := "test_monitor_audit_expire"
+	ctx := context.TODO()
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Millisecond*30),
+		WithAuditSink(sink),
+		WithKeyPrefix("app:"),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {})
+	m1.Start(group)
+	defer m1.Stop()
+
+	for _, tag := range []string{"tag1", "tag2", "tag3"} {
+		_, err := m1.Watch("test_method", tag)
+		assert.NoError(t, err)
+	}
+	time.Sleep(time.Millisecond * 100) // 超过 watchTimeout 后被 master 移除
+
+	list, err := sink.Query(ctx, "app:"+group, AuditQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list), 2)
+	for _, r := range list {
+		assert.Equal(t, r.Action, AuditExpire)
+	}
+}
+
+// streamClient 内存中的 redis stream，实现 XADD 的 MAXLEN、MINID 裁剪与 XREVRANGE
+// miniredis v2.5 不支持 stream，用于测试 redisAuditSink
+type streamClient struct {
+	redis.UniversalClient
+	adds []*redis.XAddArgs
+	msgs []redis.XMessage // 按 id 顺序
+	seq  map[int64]uint64 // ms -> 已使用的序号
+}
+
+// streamID 解析 id，不完整的 id 按 seq 补全
+func streamID(id string, seq uint64) [2]uint64 {
+	msStr, seqStr, full := strings.Cut(id, "-")
+	ms, _ := strconv.ParseUint(msStr, 10, 64)
+	if full {
+		seq, _ = strconv.ParseUint(seqStr, 10, 64)
+	}
+	return [2]uint64{ms, seq}
+}
+
+func streamLess(a, b [2]uint64) bool {
+	return a[0] < b[0] || (a[0] == b[0] && a[1] < b[1])
+}
+
+func (c *streamClient) XAdd(ctx context.Context, a *redis.XAddArgs) *redis.StringCmd {
+	c.adds = append(c.adds, a)
+	values := a.Values.(map[string]interface{})
+	ms := values["time"].(int64) // 使用记录时间作为写入时间
+	id := fmt.Sprintf("%d-%d", ms, c.seq[ms])
+	c.seq[ms]++
+	msg := redis.XMessage{ID: id, Values: make(map[string]interface{}, len(values))}
+	for k, v := range values {
+		msg.Values[k] = fmt.Sprint(v) // redis 返回的字段均为字符串
+	}
+	c.msgs = append(c.msgs, msg)
+	if a.MinID != "" {
+		min := streamID(a.MinID, 0)
+		for len(c.msgs) > 0 && streamLess(streamID(c.msgs[0].ID, 0), min) {
+			c.msgs = c.msgs[1:]
+		}
+	}
+	if a.MaxLen > 0 && int64(len(c.msgs)) > a.MaxLen {
+		c.msgs = c.msgs[int64(len(c.msgs))-a.MaxLen:]
+	}
+	return redis.NewStringResult(id, nil)
+}
+
+func (c *streamClient) XRevRangeN(ctx context.Context, stream string, start string, stop string, count int64) *redis.XMessageSliceCmd {
+	hi, lo := streamID(start, ^uint64(0)), streamID(stop, 0)
+	if start == "+" {
+		hi = [2]uint64{^uint64(0), ^uint64(0)}
+	}
+	if stop == "-" {
+		lo = [2]uint64{}
+	}
+	var list []redis.XMessage
+	for i := len(c.msgs) - 1; i >= 0 && int64(len(list)) < count; i-- {
+		id := streamID(c.msgs[i].ID, 0)
+		if !streamLess(hi, id) && !streamLess(id, lo) {
+			list = append(list, c.msgs[i])
+		}
+	}
+	return redis.NewXMessageSliceCmdResult(list, nil)
+}
+
+// 测试 redis stream 的写入、裁剪与分页查询
+func TestRedisAuditSink(t *testing.T) {
+	ctx := context.TODO()
+	group := "test_redis_audit"
+	start := time.UnixMilli(time.Now().UnixMilli())
+	key := BuildKey(group, "test_method", "tag|1")
+
+	cli := &streamClient{seq: make(map[int64]uint64)}
+	sink := NewRedisAuditSink(cli, 0, 0)
+	for i := 0; i < 1200; i++ {
+		r := AuditRecord{Action: AuditSet, Key: key, UID: "uid1", Time: start.Add(time.Duration(i/2) * time.Millisecond), Size: i}
+		if i%3 == 0 {
+			r.Key = BuildKey(group, "other_method", "tag2")
+		}
+		assert.NoError(t, sink.Append(ctx, group, r))
+	}
+	assert.Equal(t, []any{cli.adds[0].Stream, cli.adds[0].MaxLen, cli.adds[0].MinID}, []any{"{" + group + "}:Audit", int64(0), ""})
+
+	// 最近的 Limit 条，按时间顺序
+	list, err := sink.Query(ctx, group, AuditQuery{Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{len(list), list[0].Size, list[2].Size}, []any{3, 1197, 1199})
+
+	// 跨多批分页，过滤 method
+	list, err = sink.Query(ctx, group, AuditQuery{Method: "test_method", Limit: 700})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{len(list), list[0].Size, list[699].Size}, []any{700, 151, 1199})
+	for i := 1; i < len(list); i++ {
+		assert.Equal(t, list[i-1].Size < list[i].Size, true)
+	}
+	list, err = sink.Query(ctx, group, AuditQuery{Method: "test_method", Limit: 10000})
+	assert.NoError(t, err)
+	assert.Equal(t, len(list), 800)
+
+	// 时间范围，包含两端
+	list, err = sink.Query(ctx, group, AuditQuery{Start: start.Add(time.Millisecond * 10), End: start.Add(time.Millisecond * 11)})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{len(list), list[0].Size, list[3].Size}, []any{4, 20, 23})
+
+	// 按条数裁剪
+	cli = &streamClient{seq: make(map[int64]uint64)}
+	sink = NewRedisAuditSink(cli, 100, 0)
+	for i := 0; i < 150; i++ {
+		assert.NoError(t, sink.Append(ctx, group, AuditRecord{Action: AuditWatch, Key: key, Time: start.Add(time.Duration(i) * time.Millisecond)}))
+	}
+	assert.Equal(t, []any{cli.adds[0].MaxLen, cli.adds[0].Approx, cli.adds[0].MinID}, []any{int64(100), true, ""})
+	list, err = sink.Query(ctx, group, AuditQuery{Limit: 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{len(list), list[0].Time}, []any{100, start.Add(time.Millisecond * 50)})
+
+	// 按时长裁剪，MINID 为记录时间减去 maxAge
+	cli = &streamClient{seq: make(map[int64]uint64)}
+	sink = NewRedisAuditSink(cli, 100, time.Millisecond*30)
+	for i := 0; i < 50; i++ {
+		assert.NoError(t, sink.Append(ctx, group, AuditRecord{Action: AuditWatch, Key: key, Time: start.Add(time.Duration(i) * time.Millisecond)}))
+	}
+	last := cli.adds[len(cli.adds)-1]
+	assert.Equal(t, []any{last.MaxLen, last.MinID}, []any{int64(0), strconv.FormatInt(start.Add(time.Millisecond*19).UnixMilli(), 10)})
+	list, err = sink.Query(ctx, group, AuditQuery{})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{len(list), list[0].Time}, []any{31, start.Add(time.Millisecond * 19)})
+}
+
+func TestParseAuditMessage(t *testing.T) {
+	key := BuildKey("test_group", "test_method", "tag|1")
+	r := parseAuditMessage(redis.XMessage{ID: "1700000000000-1", Values: map[string]interface{}{
+		"action":   "reentry",
+		"key":      key,
+		"uid":      "uid1",
+		"owner":    "uid0",
+		"time":     "1700000000000",
+		"cost":     "1500",
+		"size":     "12",
+		"attempts": "3",
+		"err":      "failed",
+	}})
+	assert.Equal(t, r, AuditRecord{
+		ID: "1700000000000-1", Action: AuditReentry, Key: key, Method: "test_method", Tag: "tag|1",
+		UID: "uid1", Owner: "uid0", Time: time.UnixMilli(1700000000000), Cost: time.Millisecond * 1500,
+		Size: 12, Attempts: 3, Err: "failed",
+	})
+
+	// 可选字段缺失时为零值
+	r = parseAuditMessage(redis.XMessage{ID: "1-0", Values: map[string]interface{}{"action": "watch", "key": key, "time": "1"}})
+	assert.Equal(t, []any{r.Action, r.Owner, r.Cost, r.Size, r.Attempts, r.Err}, []any{AuditWatch, "", time.Duration(0), 0, int64(0), ""})
+
+	assert.Equal(t, prevStreamID("5-3"), "5-2")
+	assert.Equal(t, prevStreamID("5-0"), "4-18446744073709551615")
+	assert.Equal(t, prevStreamID("0-0"), "")
+}
@@ -0,0 +1,92 @@
+package monitor
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// ReentryMode 重入任务的分配方式
+type ReentryMode int
+
+const (
+	ReentryLocal          ReentryMode = iota // 默认，master 本地执行所有重入任务
+	ReentryLeastLoaded                       // 分配给任务数最少的存活节点
+	ReentryConsistentHash                    // 按 key 一致性哈希分配给存活节点
+)
+
+// WithReentryMode 设置重入任务的分配方式
+// 非 ReentryLocal 模式下，master 将丢失的任务分配给存活节点，节点在下一次心跳时领取并在本地执行
+func WithReentryMode(mode ReentryMode) MOpt {
+	return func(r *monitorImpl) {
+		r.reentryMode = mode
+	}
+}
+
+const ringReplicas = 64 // 一致性哈希每个节点的虚拟节点数
+
+type ringNode struct {
+	hash uint32
+	uid  string
+}
+
+// balancer 单轮 checkWatchList 使用的任务分配器
+type balancer struct {
+	mode ReentryMode
+	uids []string       // 存活节点，有序
+	load map[string]int // uid -> 任务数
+	ring []ringNode     // 一致性哈希环
+}
+
+// newBalancer nodeMap 为存活节点，watches 为任务列表 key -> uid
+func newBalancer(mode ReentryMode, nodeMap map[string]int64, watches map[string]string) *balancer {
+	b := &balancer{
+		mode: mode,
+		load: make(map[string]int, len(nodeMap)),
+	}
+	for uid := range nodeMap {
+		b.uids = append(b.uids, uid)
+		b.load[uid] = 0
+	}
+	sort.Strings(b.uids)
+	for _, uid := range watches {
+		if _, has := b.load[uid]; has {
+			b.load[uid]++
+		}
+	}
+
+	if mode == ReentryConsistentHash {
+		for _, uid := range b.uids {
+			for i := 0; i < ringReplicas; i++ {
+				b.ring = append(b.ring, ringNode{hash: crc32.ChecksumIEEE([]byte(uid + "#" + strconv.Itoa(i))), uid: uid})
+			}
+		}
+		sort.Slice(b.ring, func(i, j int) bool { return b.ring[i].hash < b.ring[j].hash })
+	}
+	return b
+}
+
+// pick 为 key 选择执行节点，没有存活节点时返回空
+func (b *balancer) pick(key string) (uid string) {
+	if len(b.uids) == 0 {
+		return
+	}
+
+	switch b.mode {
+	case ReentryConsistentHash:
+		hash := crc32.ChecksumIEEE([]byte(key))
+		i := sort.Search(len(b.ring), func(i int) bool { return b.ring[i].hash >= hash })
+		if i == len(b.ring) {
+			i = 0
+		}
+		uid = b.ring[i].uid
+	default:
+		for _, u := range b.uids {
+			if uid == "" || b.load[u] < b.load[uid] {
+				uid = u
+			}
+		}
+	}
+	b.load[uid]++ // 同一轮内后续分配考虑本次负载
+	return
+}
@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBalancer(t *testing.T) {
+	nodeMap := map[string]int64{"a": 1, "b": 1, "c": 1}
+
+	// 最少任务优先
+	bal := newBalancer(ReentryLeastLoaded, nodeMap, map[string]string{"k1": "a", "k2": "a", "k3": "b", "k4": "ghost"})
+	assert.Equal(t, []any{bal.pick("x1"), bal.pick("x2"), bal.pick("x3")}, []any{"c", "b", "c"})
+
+	// 一致性哈希：同一个 key 分配结果稳定，节点减少时只迁移该节点的 key
+	bal = newBalancer(ReentryConsistentHash, nodeMap, nil)
+	bal2 := newBalancer(ReentryConsistentHash, map[string]int64{"a": 1, "b": 1}, nil)
+	count := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("group|method|%d", i)
+		uid := bal.pick(key)
+		assert.Equal(t, bal.pick(key), uid)
+		if uid != "c" {
+			assert.Equal(t, bal2.pick(key), uid)
+		}
+		count[uid]++
+	}
+	assert.Equal(t, len(count), 3)
+
+	// 没有存活节点
+	bal = newBalancer(ReentryLeastLoaded, nil, nil)
+	assert.Equal(t, bal.pick("x"), "")
+}
+
+// 测试 master 将丢失的任务分配给存活节点执行
+func TestMonitorReentryAssign(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_reentry_assign"
+	ctx := context.TODO()
+	recorder := &eventRecorder{}
+
+	var mu sync.Mutex
+	executed := make(map[string]string) // tag -> uid
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Second),
+			WithWatchTimeout(time.Minute),
+			WithReentryMode(ReentryLeastLoaded),
+			WithEventFunc(recorder.record),
+		)
+		uid := m.(*monitorImpl).uid
+		m.Register("test_method", func(mctx MonitorContext) {
+			body, err := mctx.Get()
+			assert.NoError(t, err)
+			mu.Lock()
+			defer mu.Unlock()
+			executed[string(body)] = uid
+		})
+		m.Start(group)
+		return m
+	}
+
+	m1 := newMonitor()
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	m2 := newMonitor()
+	defer m2.Stop()
+	m3 := newMonitor()
+	defer m3.Stop()
+	time.Sleep(time.Millisecond * 20)
+
+	// 心跳超时的节点遗留的任务
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	for i := 0; i < 6; i++ {
+		key := fmt.Sprintf("%s|test_method|%d", group, i)
+		assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+		assert.NoError(t, store.SetContext(ctx, key, []byte(key), time.Minute))
+	}
+
+	assert.Eventually(t, func() bool {
+		keys, err := m1.WatchList()
+		return err == nil && len(keys) == 0
+	}, time.Second, time.Millisecond*10)
+
+	mu.Lock()
+	defer mu.Unlock()
+	count := make(map[string]int)
+	for _, uid := range executed {
+		count[uid]++
+	}
+	assert.Equal(t, len(executed), 6)
+	assert.Equal(t, []any{len(count), count[m1.(*monitorImpl).uid]}, []any{3, 2})
+
+	e, has := recorder.find(EventReentryAssigned)
+	assert.Equal(t, []any{has, e.Owner, e.Target != ""}, []any{true, "ghost", true})
+}
+
+// 测试分配给本节点的任务无法执行时，释放为无主任务，由 master 重新分配
+func TestMonitorReleaseAssigned(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_release_assigned"
+	ctx := context.TODO()
+	m := NewMonitorWithStore(store).(*monitorImpl)
+	m.group = group
+	defer m.Stop()
+	m.Register("test_method", func(mctx MonitorContext) {})
+
+	owner := func(key string) string {
+		maps, _ := store.WatchList(ctx, group)
+		return maps[key]
+	}
+	assign := func(key string) {
+		assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+		assigned, err := store.Assign(ctx, group, key, "ghost", m.uid)
+		assert.Equal(t, []any{assigned, err}, []any{true, nil})
+	}
+
+	// 未注册的方法
+	key1 := group + "|unknown_method|1"
+	assign(key1)
+	m.checkAssignments()
+	assert.Equal(t, owner(key1), orphanUID)
+
+	// Shutdown 中
+	key2 := group + "|test_method|2"
+	assign(key2)
+	m.mu.Lock()
+	m.shutting = true
+	m.mu.Unlock()
+	m.checkAssignments()
+	assert.Equal(t, owner(key2), orphanUID)
+
+	// Assign 只在任务仍属于原节点时生效
+	assigned, err := store.Assign(ctx, group, key2, "ghost", m.uid)
+	assert.Equal(t, []any{assigned, err}, []any{false, nil})
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"context"
+	"log"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCanceled 任务被 Monitor.Cancel 取消
+var ErrCanceled = errors.New("[Monitor] watch canceled: canceled by Cancel")
+
+// Cancel 取消任务，可以在任意节点调用
+func (m *monitorImpl) Cancel(method string, tag string) error {
+	return m.CancelContext(m.ctx, method, tag)
+}
+
+// CancelContext 取消任务
+// 1. 从任务列表移除并删除上下文，master 不会再重入该任务
+// 2. 广播取消信号，执行中的节点 mctx.Done 关闭、mctx.Err 返回 ErrCanceled，重入时 callback 的 ctx 同时取消
+// 广播不保证送达，未收到信号的节点在 mctx.Set 等操作时会发现上下文已不存在
+func (m *monitorImpl) CancelContext(ctx context.Context, method string, tag string) error {
+	key := m.watchKey(method, tag)
+	maps, err := m.store.WatchList(ctx, m.group)
+	if err != nil {
+		return errors.Wrap(err, "[Monitor] Cancel WatchList Error")
+	}
+	if _, has := maps[key]; !has {
+		return errors.Wrapf(ErrWatchNotFound, "key: %s", key)
+	}
+	if err = m.store.RemoveWatch(ctx, m.group, key); err != nil {
+		return errors.Wrap(err, "[Monitor] Cancel RemoveWatch Error")
+	}
+	if err = m.store.DelContext(ctx, key); err != nil {
+		return errors.Wrap(err, "[Monitor] Cancel DelContext Error")
+	}
+	m.audit(ctx, AuditRecord{Action: AuditUnwatch, Key: key, Owner: maps[key], Err: ErrCanceled.Error()})
+	err = m.store.PublishCancel(ctx, m.group, key)
+	return errors.Wrap(err, "[Monitor] Cancel PublishCancel Error")
+}
+
+// subscribeCancel 订阅取消信号，订阅结束后在下一次心跳重新订阅
+// 连续失败时从心跳时间开始翻倍退避，只在第一次失败时输出日志
+func (m *monitorImpl) subscribeCancel() {
+	if m.cancelSub != nil {
+		select {
+		case <-m.cancelSub:
+			m.cancelSub = nil
+			log.Println("[Monitor] SubscribeCancel closed, resubscribe")
+		default:
+			return // 订阅中
+		}
+	}
+	now := m.clock.Now()
+	if now.Before(m.subscribeRetryAt) {
+		return
+	}
+	done, err := m.store.SubscribeCancel(m.cancelCtx, m.group, m.onCancel)
+	if err != nil {
+		if m.subscribeFails == 0 {
+			log.Println("[Monitor] SubscribeCancel Error:", err)
+		}
+		shift := m.subscribeFails
+		if shift > maxBackoffShift {
+			shift = maxBackoffShift
+		}
+		m.subscribeFails++
+		m.subscribeRetryAt = now.Add(m.heartbeatTime << shift)
+		return
+	}
+	if m.subscribeFails > 0 {
+		log.Printf("[Monitor] SubscribeCancel recovered, failures: %d", m.subscribeFails)
+	}
+	m.subscribeFails = 0
+	m.cancelSub = done
+}
+
+// onCancel 收到取消信号，取消本地执行中的任务
+func (m *monitorImpl) onCancel(key string) {
+	m.mu.Lock()
+	lw, has := m.localWatchMap[key]
+	if has {
+		lw.canceled = true // 不再升级预警
+		if c, ok := lw.mctx.(canceler); ok {
+			c.cancel(ErrCanceled)
+		}
+		// 移除本地任务，Watch 的代码忽略 Done 时不会泄漏，之后的 Unwatch 被忽略
+		m.abandonLocked(key, lw)
+	}
+	m.mu.Unlock()
+	if !has {
+		return // 任务不在本节点执行
+	}
+
+	log.Printf("[Monitor] cancel MonitorContext key: %s", key)
+	e := taskEvent(EventWatchCanceled, key, m.uid)
+	e.StartAt, e.Cost, e.Err = lw.startAt, m.clock.Since(lw.startAt), ErrCanceled
+	m.emit(e)
+}
@@ -0,0 +1,183 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试在其他节点取消执行中的任务
+func TestMonitorCancel(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_cancel"
+	recorder := &eventRecorder{}
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {})
+	m1.Start(group)
+	defer m1.Stop()
+	m2 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithEventFunc(recorder.record),
+	)
+	m2.Register("test_method", func(mctx MonitorContext) {})
+	m2.Start(group)
+	defer m2.Stop()
+	time.Sleep(time.Millisecond * 20) // 等待订阅完成
+
+	mctx, err := m2.Watch("test_method", "tag1", []byte("data"))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, m1.Cancel("test_method", "tag2"), ErrWatchNotFound)
+	assert.NoError(t, m1.Cancel("test_method", "tag1"))
+
+	select {
+	case <-mctx.Done():
+		assert.ErrorIs(t, mctx.Err(), ErrCanceled)
+	case <-time.After(time.Second):
+		t.Fatal("cancel timeout")
+	}
+	list, _ := m1.WatchList()
+	assert.Equal(t, len(list), 0)
+	valid, err := mctx.Check()
+	assert.Equal(t, []any{valid, err}, []any{false, nil})
+	e, has := recorder.find(EventWatchCanceled)
+	assert.Equal(t, []any{has, e.Tag, e.Err}, []any{true, "tag1", ErrCanceled})
+
+	// 本地任务已移除，忽略 Done 的代码之后调用 Unwatch 不报错
+	impl := m2.(*monitorImpl)
+	impl.mu.RLock()
+	_, local := impl.localWatchMap[BuildKey(group, "test_method", "tag1")]
+	impl.mu.RUnlock()
+	assert.Equal(t, local, false)
+	assert.NoError(t, m2.Unwatch("test_method", "tag1"))
+}
+
+// 测试取消重入中的任务，callback 返回 error 后不再重入
+func TestMonitorCancelReentry(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_cancel_reentry"
+	ctx := context.TODO()
+	var calls int32
+	done := make(chan error, 1)
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+	)
+	m1.RegisterWithError("test_method", func(ctx context.Context, mctx MonitorContext) error {
+		atomic.AddInt32(&calls, 1)
+		<-ctx.Done()
+		done <- mctx.Err()
+		return errors.New("canceled")
+	})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	key := BuildKey(group, "test_method", "tag1")
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, time.Millisecond*5)
+
+	assert.NoError(t, m1.Cancel("test_method", "tag1"))
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrCanceled)
+	case <-time.After(time.Second):
+		t.Fatal("cancel timeout")
+	}
+	time.Sleep(time.Millisecond * 50) // 等待几次心跳，确认不再重入
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+	maps, _ := store.WatchList(ctx, group)
+	assert.Equal(t, len(maps), 0)
+}
+
+// subscribeStore 订阅在 fails 次之前失败，unsubscribe 模拟订阅连接关闭
+type subscribeStore struct {
+	Store
+	mu          sync.Mutex
+	fails       int
+	calls       int
+	subscribed  int
+	unsubscribe context.CancelFunc
+}
+
+func (s *subscribeStore) SubscribeCancel(ctx context.Context, group string, fn func(key string)) (<-chan struct{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.calls <= s.fails {
+		return nil, errors.New("pub/sub unavailable")
+	}
+	subCtx, cancel := context.WithCancel(ctx)
+	done, err := s.Store.SubscribeCancel(subCtx, group, fn)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	s.subscribed++
+	s.unsubscribe = cancel
+	return done, nil
+}
+
+func (s *subscribeStore) counts() (calls int, subscribed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls, s.subscribed
+}
+
+// 测试订阅失败时退避重试，订阅关闭后重新订阅
+func TestMonitorCancelResubscribe(t *testing.T) {
+	store := &subscribeStore{Store: NewMemoryStore(), fails: 4}
+	group := "test_monitor_cancel_resubscribe"
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {})
+	m1.Start(group)
+	defer m1.Stop()
+
+	// 失败后间隔 10ms、20ms、40ms、80ms 重试
+	time.Sleep(time.Millisecond * 100)
+	calls, subscribed := store.counts()
+	assert.Equal(t, []any{calls >= 3 && calls <= 4, subscribed}, []any{true, 0}, calls)
+	assert.Eventually(t, func() bool {
+		_, subscribed := store.counts()
+		return subscribed == 1
+	}, time.Second, time.Millisecond*10)
+
+	cancelWatch := func(tag string) {
+		mctx, err := m1.Watch("test_method", tag)
+		assert.NoError(t, err)
+		assert.NoError(t, m1.Cancel("test_method", tag))
+		select {
+		case <-mctx.Done():
+			assert.ErrorIs(t, mctx.Err(), ErrCanceled)
+		case <-time.After(time.Second):
+			t.Fatal("cancel timeout")
+		}
+	}
+	cancelWatch("tag1")
+
+	// 订阅关闭后在下一次心跳重新订阅
+	store.mu.Lock()
+	store.unsubscribe()
+	store.mu.Unlock()
+	assert.Eventually(t, func() bool {
+		_, subscribed := store.counts()
+		return subscribed == 2
+	}, time.Second, time.Millisecond*10)
+	cancelWatch("tag2")
+}
@@ -0,0 +1,99 @@
+package monitor
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StepStatus 步骤状态
+type StepStatus string
+
+const (
+	StepRunning   StepStatus = "running"   // 执行中
+	StepCompleted StepStatus = "completed" // 已完成，重入时可以跳过
+	StepFailed    StepStatus = "failed"    // 执行失败
+)
+
+// Step 任务步骤，与上下文存储在一起，Close 时一同清理
+type Step struct {
+	Name      string     `json:"name"`
+	Status    StepStatus `json:"status"`
+	Data      []byte     `json:"data"` // 步骤数据，例如外部订单号
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Checkpoint 记录步骤的状态与数据，同时刷新上下文时长
+// 单个步骤的写入是原子的，多步骤任务重入时可以据此跳过已完成的步骤
+func (c *monitorContext) Checkpoint(step string, status StepStatus, data []byte) error {
+	body, err := json.Marshal(Step{Name: step, Status: status, Data: data, UpdatedAt: c.clock.Now()})
+	if err != nil {
+		return errors.Wrap(err, "[MonitorContext] Checkpoint Marshal Error")
+	}
+	c.mu.Lock()
+	c.expiredTime = c.clock.Now().Add(c.expiredDur) // 更新超时时间
+	c.mu.Unlock()
+	err = c.store.SetStep(c.ctx, c.key, step, body, c.expiredDur)
+	return errors.Wrap(err, "[MonitorContext] Checkpoint Error")
+}
+
+// Step 获取步骤，不存在返回 ErrNil
+func (c *monitorContext) Step(step string) (s Step, err error) {
+	steps, err := c.getSteps()
+	if err != nil {
+		return
+	}
+	s, has := steps[step]
+	if !has {
+		err = errors.Wrap(ErrNil, "[MonitorContext] Step Error")
+	}
+	return
+}
+
+// Steps 获取全部步骤，按更新时间排序
+func (c *monitorContext) Steps() (list []Step, err error) {
+	steps, err := c.getSteps()
+	if err != nil {
+		return
+	}
+	list = make([]Step, 0, len(steps))
+	for _, s := range steps {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].UpdatedAt.Equal(list[j].UpdatedAt) {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].UpdatedAt.Before(list[j].UpdatedAt)
+	})
+	return
+}
+
+// Completed 步骤是否已完成
+func (c *monitorContext) Completed(step string) (bool, error) {
+	s, err := c.Step(step)
+	if errors.Is(err, ErrNil) {
+		return false, nil
+	}
+	return s.Status == StepCompleted, err
+}
+
+func (c *monitorContext) getSteps() (steps map[string]Step, err error) {
+	maps, err := c.store.GetSteps(c.ctx, c.key)
+	if err != nil {
+		err = errors.Wrap(err, "[MonitorContext] Steps Error")
+		return
+	}
+	steps = make(map[string]Step, len(maps))
+	for name, body := range maps {
+		var s Step
+		if err = json.Unmarshal(body, &s); err != nil {
+			err = errors.Wrapf(err, "[MonitorContext] Steps Unmarshal Error, step: %s", name)
+			return
+		}
+		steps[name] = s
+	}
+	return
+}
@@ -0,0 +1,96 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FredyXue/go-utils/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	stores := map[string]Store{
+		"redis":  NewRedisStore(testdata.NewTestRedis()),
+		"memory": NewMemoryStore(),
+	}
+	for name, store := range stores {
+		key := "test_checkpoint_" + name + "|test_method|1"
+		mctx := NewMonitorContextWithStore(store, key, time.Minute)
+		assert.NoError(t, mctx.Set([]byte("body")), name)
+
+		_, err := mctx.Step("charge")
+		assert.Equal(t, errors.Is(err, ErrNil), true, name)
+		done, err := mctx.Completed("charge")
+		assert.Equal(t, []any{done, err}, []any{false, nil}, name)
+
+		assert.NoError(t, mctx.Checkpoint("charge", StepCompleted, []byte("order_1")), name)
+		time.Sleep(time.Millisecond)
+		assert.NoError(t, mctx.Checkpoint("notify", StepRunning, nil), name)
+
+		step, err := mctx.Step("charge")
+		assert.NoError(t, err, name)
+		assert.Equal(t, []any{step.Name, step.Status, string(step.Data)}, []any{"charge", StepCompleted, "order_1"}, name)
+		done, _ = mctx.Completed("charge")
+		assert.Equal(t, done, true, name)
+		done, _ = mctx.Completed("notify")
+		assert.Equal(t, done, false, name)
+
+		steps, err := mctx.Steps()
+		assert.NoError(t, err, name)
+		assert.Equal(t, []any{len(steps), steps[0].Name, steps[1].Name}, []any{2, "charge", "notify"}, name)
+
+		// 上下文与步骤的时长一致
+		ttl, _ := store.ContextTTL(context.TODO(), key)
+		assert.Equal(t, ttl > 0, true, name)
+
+		// Close 时一同清理
+		assert.NoError(t, mctx.Close(), name)
+		steps, err = mctx.Steps()
+		assert.NoError(t, err, name)
+		assert.Equal(t, len(steps), 0, name)
+	}
+}
+
+// 测试重入时跳过已完成的步骤
+func TestMonitorCheckpointReentry(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_checkpoint"
+	charged := make(chan string, 10)
+	done := make(chan struct{}, 1)
+
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Second),
+			WithWatchTimeout(time.Minute),
+		)
+		m.Register("test_method", func(mctx MonitorContext) {
+			if completed, _ := mctx.Completed("charge"); !completed {
+				charged <- "reentry"
+			}
+			done <- struct{}{}
+		})
+		m.Start(group)
+		return m
+	}
+
+	m1 := newMonitor()
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	m2 := newMonitor()
+
+	mctx, err := m2.Watch("test_method", "1", nil)
+	assert.NoError(t, err)
+	charged <- "m2"
+	assert.NoError(t, mctx.Checkpoint("charge", StepCompleted, nil))
+	m2.Stop() // 第二步执行前退出，由 m1 重入
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+	assert.Equal(t, []any{len(charged), <-charged}, []any{1, "m2"})
+}
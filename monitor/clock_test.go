@@ -0,0 +1,98 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FredyXue/go-utils/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试使用 FakeClock 驱动心跳、节点过期与长耗时预警，分钟级的配置在毫秒内完成
+func TestMonitorClock(t *testing.T) {
+	clock := testdata.NewFakeClock(time.Now())
+	store := NewMemoryStore(WithMemoryClock(clock))
+	group := "test_monitor_clock"
+	ctx := context.TODO()
+	recorder := &eventRecorder{}
+	done := make(chan string, 1)
+
+	m1 := NewMonitorWithStore(store,
+		WithClock(clock),
+		WithHeartbeatTime(time.Minute),
+		WithHeartbeatTimeout(time.Minute*3),
+		WithEventFunc(recorder.record),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {
+		body, _ := mctx.Get()
+		done <- string(body)
+	}, CallOpt{WatchWarningTime: time.Minute * 10})
+	m1.Start(group)
+	defer m1.Stop()
+	// 等待选举完成，心跳与 Locker 续约各一个 Ticker
+	assert.Eventually(t, func() bool { return m1.IsMaster() && clock.Tickers() == 2 }, time.Second, time.Millisecond)
+
+	// 节点 ghost 心跳后崩溃
+	key := BuildKey(group, "test_method", "tag1")
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", clock.Now().Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", clock.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Hour))
+
+	clock.Step(time.Minute*2, time.Minute) // 心跳未超时
+	select {
+	case <-done:
+		t.Fatal("reentry before heartbeat timeout")
+	default:
+	}
+	clock.Step(time.Minute*2, time.Minute) // 心跳超时，重入
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "body")
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+	e, has := recorder.find(EventNodeExpired)
+	assert.Equal(t, []any{has, e.Owner}, []any{true, "ghost"})
+
+	// 长耗时预警按模拟时间计算
+	_, err := m1.Watch("test_method", "tag2")
+	assert.NoError(t, err)
+	times, err := store.WatchTimes(ctx, group) // 任务时间同样使用模拟时间
+	assert.Equal(t, []any{times[BuildKey(group, "test_method", "tag2")], err}, []any{clock.Now().Unix(), nil})
+	clock.Step(time.Minute*11, time.Minute)
+	assert.Eventually(t, func() bool {
+		e, has := recorder.find(EventLongRunning)
+		return has && e.Tag == "tag2"
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, m1.IsMaster(), true) // Locker 随时钟续约，master 保持不变
+
+	// 上下文按模拟时间过期
+	clock.Advance(time.Hour)
+	_, err = store.GetContext(ctx, key)
+	assert.ErrorIs(t, err, ErrNil)
+}
+
+// 测试 memory 存储使用注入的时钟计算过期时间
+func TestMemoryStoreClock(t *testing.T) {
+	clock := testdata.NewFakeClock(time.Now())
+	store := NewMemoryStore(WithMemoryClock(clock))
+	ctx := context.TODO()
+	group := "test_memory_store_clock"
+	key := BuildKey(group, "test_method", "tag1")
+
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+	assert.NoError(t, store.SetStep(ctx, key, "step1", []byte("done"), time.Minute))
+	assert.NoError(t, store.SetMaster(ctx, group, "uid1", time.Minute))
+	clock.Advance(time.Second * 59)
+	body, err := store.GetContext(ctx, key)
+	assert.Equal(t, []any{string(body), err}, []any{"body", nil})
+
+	clock.Advance(time.Second * 2)
+	_, err = store.GetContext(ctx, key)
+	assert.ErrorIs(t, err, ErrNil)
+	steps, _ := store.GetSteps(ctx, key)
+	assert.Equal(t, len(steps), 0)
+	_, err = store.GetMaster(ctx, group)
+	assert.ErrorIs(t, err, ErrNil)
+}
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Codec 上下文数据的编解码
+// 默认 JSONCodec，protobuf 等其他格式可以自行实现
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	JSONCodec Codec = jsonCodec{}
+	GobCodec  Codec = gobCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// WithCodec 设置 WatchTyped/GetTyped/SetTyped 使用的编解码，默认 JSONCodec
+// 同一个 group 内的节点需要使用相同的 Codec
+func WithCodec(codec Codec) MOpt {
+	return func(r *monitorImpl) {
+		r.codec = codec
+	}
+}
+
+// codecHolder 携带 Codec 的 Monitor 与 MonitorContext
+type codecHolder interface {
+	getCodec() Codec
+}
+
+// codecOf 获取对象的 Codec，默认 JSONCodec
+func codecOf(v any) Codec {
+	if h, ok := v.(codecHolder); ok && h.getCodec() != nil {
+		return h.getCodec()
+	}
+	return JSONCodec
+}
+
+// WatchTyped 任务监控，使用 Monitor 的 Codec 编码上下文数据
+func WatchTyped[T any](m Monitor, method string, tag string, data T) (mctx MonitorContext, err error) {
+	body, err := codecOf(m).Marshal(data)
+	if err != nil {
+		err = errors.Wrap(err, "[Monitor] WatchTyped Marshal Error")
+		return
+	}
+	return m.Watch(method, tag, body)
+}
+
+// GetTyped 获取上下文并解码，上下文不存在返回 ErrNil
+func GetTyped[T any](mctx MonitorContext) (v T, err error) {
+	body, err := mctx.Get()
+	if err != nil {
+		return
+	}
+	if err = codecOf(mctx).Unmarshal(body, &v); err != nil {
+		err = errors.Wrap(err, "[MonitorContext] GetTyped Unmarshal Error")
+	}
+	return
+}
+
+// SetTyped 编码并更新上下文
+func SetTyped[T any](mctx MonitorContext, v T) error {
+	body, err := codecOf(mctx).Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "[MonitorContext] SetTyped Marshal Error")
+	}
+	return mctx.Set(body)
+}
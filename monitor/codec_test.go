@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type typedObj struct {
+	Title  string
+	Number int
+}
+
+func TestTypedContext(t *testing.T) {
+	for name, codec := range map[string]Codec{"json": JSONCodec, "gob": GobCodec} {
+		group := "test_typed_context_" + name
+		done := make(chan typedObj, 1)
+
+		m1 := NewMonitorWithStore(NewMemoryStore(),
+			WithHeartbeatTime(time.Millisecond*10),
+			WithCodec(codec),
+		)
+		m1.Register("test_method", func(mctx MonitorContext) {
+			obj, err := GetTyped[typedObj](mctx)
+			assert.NoError(t, err, name)
+			done <- obj
+		})
+		m1.Start(group)
+
+		mctx, err := WatchTyped(m1, "test_method", "1", typedObj{Title: "m1", Number: 1})
+		assert.NoError(t, err, name)
+		obj, err := GetTyped[typedObj](mctx)
+		assert.NoError(t, err, name)
+		assert.Equal(t, obj, typedObj{Title: "m1", Number: 1}, name)
+
+		// 中途更新上下文
+		assert.NoError(t, SetTyped(mctx, typedObj{Title: "m1", Number: 2}), name)
+		body, _ := mctx.Get()
+		want, _ := codec.Marshal(typedObj{Title: "m1", Number: 2})
+		assert.Equal(t, body, want, name)
+
+		// 重入时使用相同的 Codec 解码
+		impl := m1.(*monitorImpl)
+		impl.mu.Lock()
+		delete(impl.localWatchMap, group+"|test_method|1")
+		impl.mu.Unlock()
+		assert.NoError(t, impl.store.AddWatch(impl.ctx, group, group+"|test_method|1", "ghost", time.Now().Unix()))
+		select {
+		case obj = <-done:
+			assert.Equal(t, obj, typedObj{Title: "m1", Number: 2}, name)
+		case <-time.After(time.Second):
+			t.Fatal("reentry timeout", name)
+		}
+		m1.Stop()
+
+		// 解码失败
+		assert.NoError(t, mctx.Set([]byte("invalid")), name)
+		_, err = GetTyped[typedObj](mctx)
+		assert.Equal(t, err != nil && !errors.Is(err, ErrNil), true, name)
+
+		// 上下文不存在
+		assert.NoError(t, mctx.Close(), name)
+		_, err = GetTyped[typedObj](mctx)
+		assert.ErrorIs(t, err, ErrNil, name)
+	}
+}
@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试并发 Watch/Unwatch 与选举、重入同时进行
+// go test -race
+func TestMonitorConcurrency(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_concurrency"
+	var reentryCount int64
+	reentered := sync.Map{}
+
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*5),
+			WithHeartbeatTimeout(time.Second),
+			WithWatchTimeout(time.Minute),
+			WithWatchWarningTime(time.Millisecond), // 同时触发长耗时检测
+		)
+		m.Register("test_method", func(mctx MonitorContext) {
+			body, err := mctx.Get()
+			assert.NoError(t, err)
+			reentered.Store(string(body), true)
+			atomic.AddInt64(&reentryCount, 1)
+		})
+		m.Start(group)
+		return m
+	}
+
+	monitors := []Monitor{newMonitor(), newMonitor(), newMonitor()}
+	time.Sleep(time.Millisecond * 10) // 等待选举
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			m := monitors[i%len(monitors)]
+			for j := 0; j < 50; j++ {
+				tag := fmt.Sprintf("%d_%d", i, j)
+				mctx, err := m.Watch("test_method", tag, []byte(tag))
+				assert.NoError(t, err)
+				assert.NoError(t, mctx.Set([]byte(tag)))
+				_, err = m.WatchList()
+				assert.NoError(t, err)
+				m.IsMaster()
+				assert.NoError(t, m.Unwatch("test_method", tag))
+			}
+		}(i)
+	}
+
+	// 并发注册与注销
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			for _, m := range monitors {
+				m.Register("test_method_tmp", func(mctx MonitorContext) {})
+				m.Deregister("test_method_tmp")
+			}
+		}
+	}()
+
+	// 并发选举：遗留任务后退出的节点，由新 master 重入
+	leftover := make([]string, 0)
+	for i := 0; i < 2; i++ {
+		m := monitors[i]
+		for j := 0; j < 5; j++ {
+			tag := fmt.Sprintf("leftover_%d_%d", i, j)
+			_, err := m.Watch("test_method", tag, []byte(tag))
+			assert.NoError(t, err)
+			leftover = append(leftover, tag)
+		}
+		time.Sleep(time.Millisecond * 10)
+		m.Stop()
+	}
+	wg.Wait()
+
+	last := monitors[2]
+	defer last.Stop()
+	assert.Eventually(t, func() bool {
+		keys, err := last.WatchList()
+		return err == nil && len(keys) == 0
+	}, time.Second*3, time.Millisecond*10)
+	assert.Equal(t, last.IsMaster(), true)
+
+	for _, tag := range leftover {
+		_, has := reentered.Load(tag)
+		assert.Equal(t, has, true, tag)
+	}
+	assert.Equal(t, atomic.LoadInt64(&reentryCount) >= int64(len(leftover)), true)
+}
+
+// concurrencyRecorder 记录同时执行的最大数量
+type concurrencyRecorder struct {
+	mu      sync.Mutex
+	current map[string]int
+	max     map[string]int
+	total   int
+	maxAll  int
+	done    int
+}
+
+func (r *concurrencyRecorder) run(method string) {
+	r.mu.Lock()
+	r.current[method]++
+	r.total++
+	if r.current[method] > r.max[method] {
+		r.max[method] = r.current[method]
+	}
+	if r.total > r.maxAll {
+		r.maxAll = r.total
+	}
+	r.mu.Unlock()
+
+	time.Sleep(time.Millisecond * 30)
+
+	r.mu.Lock()
+	r.current[method]--
+	r.total--
+	r.done++
+	r.mu.Unlock()
+}
+
+// 测试重入并发上限，超过上限的任务排队执行
+func TestMonitorReentryConcurrency(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_reentry_concurrency"
+	ctx := context.TODO()
+	recorder := &concurrencyRecorder{current: make(map[string]int), max: make(map[string]int)}
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+		WithMaxReentryConcurrency(3),
+	)
+	m1.Register("method_a", func(mctx MonitorContext) { recorder.run("method_a") }, CallOpt{MaxConcurrency: 1})
+	m1.Register("method_b", func(mctx MonitorContext) { recorder.run("method_b") })
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	// 心跳超时的节点遗留的任务
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	for _, method := range []string{"method_a", "method_b"} {
+		for i := 0; i < 4; i++ {
+			key := fmt.Sprintf("%s|%s|%d", group, method, i)
+			assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+			assert.NoError(t, store.SetContext(ctx, key, nil, time.Minute))
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		keys, err := m1.WatchList()
+		return err == nil && len(keys) == 0
+	}, time.Second*3, time.Millisecond*10)
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	assert.Equal(t, []any{recorder.done, recorder.max["method_a"], recorder.maxAll}, []any{8, 1, 3})
+}
@@ -0,0 +1,309 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/FredyXue/go-utils"
+	"github.com/pkg/errors"
+)
+
+// maxCronCatchUp CatchUpAll 单次心跳最多补执行的次数，剩余的在之后的心跳中继续
+const maxCronCatchUp = 100
+
+// CronFunc 定时任务，runAt 为本次执行的计划时间。ctx 在 Stop 时被取消
+type CronFunc func(ctx context.Context, runAt time.Time) error
+
+// CatchUpPolicy master 切换等原因错过执行时的补偿策略
+type CatchUpPolicy int
+
+const (
+	CatchUpOnce CatchUpPolicy = iota // 错过的多次执行合并为一次，runAt 为最近一次的计划时间。默认
+	CatchUpSkip                      // 跳过错过的执行，等待下一次计划时间
+	CatchUpAll                       // 按计划时间逐个补执行
+)
+
+// CronOpt 定时任务配置
+type CronOpt struct {
+	CatchUp CatchUpPolicy
+}
+
+// cronJob 定时任务
+type cronJob struct {
+	name     string
+	schedule cronSchedule
+	fn       CronFunc
+	opt      CronOpt
+	running  atomic.Bool // 上一次执行尚未结束
+}
+
+// cronSchedule 计算下一次执行时间，不存在时返回零值
+type cronSchedule interface {
+	Next(t time.Time) time.Time
+}
+
+// RegisterCron 注册定时任务，只在 master 上执行
+// spec 支持 5 段 cron 表达式（分 时 日 月 周）、@hourly 等描述符与 @every 5m
+// 最近一次执行的计划时间记录在存储中，master 切换后据此按 CronOpt.CatchUp 补偿错过的执行
+// 执行精度取决于心跳间隔，同一个定时任务不会并发执行
+func (m *monitorImpl) RegisterCron(name string, spec string, fn CronFunc, opt ...CronOpt) error {
+	schedule, err := parseCron(spec)
+	if err != nil {
+		return err
+	}
+	job := &cronJob{name: name, schedule: schedule, fn: fn}
+	if len(opt) > 0 {
+		job.opt = opt[0]
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.crons[name] = job
+	return nil
+}
+
+// checkCrons 只有 master 会执行该方法，执行到期的定时任务
+func (m *monitorImpl) checkCrons() {
+	m.mu.RLock()
+	jobs := make([]*cronJob, 0, len(m.crons))
+	for _, job := range m.crons {
+		jobs = append(jobs, job)
+	}
+	m.mu.RUnlock()
+	if len(jobs) == 0 {
+		return
+	}
+
+	times, err := m.store.CronTimes(m.ctx, m.group)
+	if err != nil {
+		log.Println("[Monitor] checkCrons CronTimes Error:", err)
+		return
+	}
+	now := m.clock.Now()
+	for _, job := range jobs {
+		job := job
+		if !job.running.CompareAndSwap(false, true) {
+			continue // 上一次执行尚未结束
+		}
+		last, has := times[job.name]
+		if !has {
+			// 首次注册，从当前时间开始计划
+			if err := m.store.SetCronTime(m.ctx, m.group, job.name, now); err != nil {
+				log.Printf("[Monitor] checkCrons SetCronTime name: %s, Error: %v", job.name, err)
+			}
+			job.running.Store(false)
+			continue
+		}
+
+		runs, latest := job.dueRuns(time.UnixMilli(last), now, m.heartbeatTime*2)
+		if latest.IsZero() {
+			job.running.Store(false)
+			continue
+		}
+		go utils.Protect(func() {
+			defer job.running.Store(false)
+			m.runCron(job, runs, latest)
+		})
+	}
+}
+
+// dueRuns 计算需要执行的计划时间，latest 为执行后记录的时间，零值表示没有到期
+// tolerance 内的计划时间视为按时，超过的视为错过
+func (j *cronJob) dueRuns(last time.Time, now time.Time, tolerance time.Duration) (runs []time.Time, latest time.Time) {
+	for t := j.schedule.Next(last); !t.IsZero() && !t.After(now); t = j.schedule.Next(t) {
+		latest = t
+		if j.opt.CatchUp == CatchUpAll {
+			if runs = append(runs, t); len(runs) >= maxCronCatchUp {
+				break
+			}
+		}
+	}
+	if latest.IsZero() {
+		return
+	}
+	switch j.opt.CatchUp {
+	case CatchUpOnce:
+		runs = []time.Time{latest}
+	case CatchUpSkip:
+		if now.Sub(latest) <= tolerance {
+			runs = []time.Time{latest}
+		}
+	}
+	return
+}
+
+// runCron 按计划时间依次执行，每次执行后记录计划时间
+// 执行中 master 崩溃时不会记录，新的 master 会重新执行，fn 需要幂等
+// 失去 master 或 Stop 时传给 fn 的 ctx 被取消，不再执行剩余的计划，也不再记录计划时间
+func (m *monitorImpl) runCron(job *cronJob, runs []time.Time, latest time.Time) {
+	ctx := m.leaderContext()
+	leading := func() bool {
+		return ctx.Err() == nil && m.IsMaster()
+	}
+	for _, runAt := range runs {
+		if !leading() {
+			return // 已 Stop 或失去 master
+		}
+		startAt := m.clock.Now()
+		var err error
+		if panicErr := utils.ProtectV2(func() { err = job.fn(ctx, runAt) }); panicErr != nil {
+			err = panicErr
+		}
+		if err != nil {
+			log.Printf("[Monitor] cron name: %s, runAt: %s, Error: %v", job.name, runAt.Format(time.RFC3339), err)
+		}
+		m.emit(Event{Type: EventCronFinished, Method: job.name, StartAt: startAt, Cost: m.clock.Since(startAt), Err: err})
+		if !leading() {
+			return // 执行期间失去 master，由新的 master 记录
+		}
+		if err = m.store.SetCronTime(m.ctx, m.group, job.name, runAt); err != nil {
+			log.Printf("[Monitor] cron SetCronTime name: %s, Error: %v", job.name, err)
+			return
+		}
+	}
+	// 跳过的执行同样记录，避免重复计算
+	if (len(runs) == 0 || runs[len(runs)-1].Before(latest)) && leading() {
+		if err := m.store.SetCronTime(m.ctx, m.group, job.name, latest); err != nil {
+			log.Printf("[Monitor] cron SetCronTime name: %s, Error: %v", job.name, err)
+		}
+	}
+}
+
+// cronDescriptors 预定义的表达式
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron 解析定时任务表达式
+func parseCron(spec string) (cronSchedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil || d <= 0 {
+			return nil, errors.Errorf("[Monitor] invalid cron spec: %s", spec)
+		}
+		return everySchedule(d), nil
+	}
+	if s, has := cronDescriptors[spec]; has {
+		spec = s
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("[Monitor] invalid cron spec: %s, expected 5 fields", spec)
+	}
+	s := &cronSpec{}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	bits := []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow}
+	for i, field := range fields {
+		v, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "[Monitor] invalid cron spec: %s", spec)
+		}
+		*bits[i] = v
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 与 0 均表示周日
+	}
+	s.domAll = strings.HasPrefix(fields[2], "*")
+	s.dowAll = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// parseCronField 解析单个字段，支持 *、数字、a-b、/n 与逗号分隔的列表
+func parseCronField(field string, min int, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, errors.Errorf("invalid step: %s", part)
+			}
+			part = part[:i]
+		}
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			arr := strings.SplitN(part, "-", 2)
+			if lo, err = strconv.Atoi(arr[0]); err != nil {
+				return 0, errors.Errorf("invalid range: %s", part)
+			}
+			if hi, err = strconv.Atoi(arr[1]); err != nil {
+				return 0, errors.Errorf("invalid range: %s", part)
+			}
+		default:
+			if lo, err = strconv.Atoi(part); err != nil {
+				return 0, errors.Errorf("invalid value: %s", part)
+			}
+			if step == 1 {
+				hi = lo
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("out of range: %s", part)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return
+}
+
+// everySchedule 固定间隔，从上一次的计划时间开始计算
+type everySchedule time.Duration
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(s))
+}
+
+// cronSpec 5 段 cron 表达式，每个字段以 bit 表示
+// 日与周同时指定时满足其一即可，与标准 cron 一致
+type cronSpec struct {
+	minute, hour, dom, month, dow uint64
+	domAll, dowAll                bool
+}
+
+// Next 下一次执行时间，精确到分钟，使用 t 的时区。5 年内不存在时返回零值
+func (s *cronSpec) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatch(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSpec) dayMatch(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAll || s.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
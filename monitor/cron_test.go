@@ -0,0 +1,174 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	base := time.Date(2024, 1, 31, 10, 7, 30, 0, time.UTC) // 周三
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 15, 0, 0, time.UTC)},
+		{"5,50 9-11 * * *", time.Date(2024, 1, 31, 10, 50, 0, 0, time.UTC)},
+		{"0 0 * * *", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"30 8 * * 1-5", time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 5", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)}, // 日与周满足其一
+		{"@every 90s", base.Add(time.Second * 90)},
+	}
+	for _, c := range cases {
+		s, err := parseCron(c.spec)
+		assert.NoError(t, err, c.spec)
+		assert.Equal(t, s.Next(base), c.next, c.spec)
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *", "@every -1s", "@every x"} {
+		_, err := parseCron(spec)
+		assert.Error(t, err, spec)
+	}
+	s, _ := parseCron("0 0 30 2 *")
+	assert.Equal(t, s.Next(base).IsZero(), true)
+}
+
+func TestCronDueRuns(t *testing.T) {
+	schedule, _ := parseCron("@every 10m")
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := last.Add(time.Minute * 35)
+
+	job := &cronJob{schedule: schedule, opt: CronOpt{CatchUp: CatchUpAll}}
+	runs, latest := job.dueRuns(last, now, time.Minute)
+	assert.Equal(t, runs, []time.Time{last.Add(time.Minute * 10), last.Add(time.Minute * 20), last.Add(time.Minute * 30)})
+	assert.Equal(t, latest, last.Add(time.Minute*30))
+
+	job.opt.CatchUp = CatchUpOnce
+	runs, latest = job.dueRuns(last, now, time.Minute)
+	assert.Equal(t, []any{runs, latest}, []any{[]time.Time{last.Add(time.Minute * 30)}, last.Add(time.Minute * 30)})
+
+	// 最近一次计划时间已超过 tolerance，视为错过
+	job.opt.CatchUp = CatchUpSkip
+	runs, latest = job.dueRuns(last, now, time.Minute)
+	assert.Equal(t, []any{len(runs), latest}, []any{0, last.Add(time.Minute * 30)})
+	runs, _ = job.dueRuns(last, last.Add(time.Minute*30+time.Second), time.Minute)
+	assert.Equal(t, runs, []time.Time{last.Add(time.Minute * 30)})
+
+	runs, latest = job.dueRuns(last, last.Add(time.Minute), time.Minute)
+	assert.Equal(t, []any{len(runs), latest.IsZero()}, []any{0, true})
+}
+
+// 测试定时任务只在 master 上执行，以及 master 切换后补偿错过的执行
+func TestMonitorCron(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_cron"
+	ctx := context.TODO()
+
+	var mu sync.Mutex
+	runs := make(map[string][]time.Time) // uid:name -> runAt
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Second),
+		)
+		uid := m.(*monitorImpl).uid
+		for _, name := range []string{"every", "all", "once"} {
+			name := name
+			opt := CronOpt{}
+			if name == "all" {
+				opt.CatchUp = CatchUpAll
+			}
+			spec := "@every 10m"
+			if name == "every" {
+				spec = "@every 50ms"
+			}
+			assert.NoError(t, m.RegisterCron(name, spec, func(ctx context.Context, runAt time.Time) error {
+				mu.Lock()
+				defer mu.Unlock()
+				runs[uid+":"+name] = append(runs[uid+":"+name], runAt)
+				return nil
+			}, opt))
+		}
+		assert.Error(t, m.RegisterCron("invalid", "* * *", nil))
+		return m
+	}
+
+	// 上一个 master 在 35 分钟前最后一次执行
+	last := time.Now().Add(-time.Minute * 35).Truncate(time.Millisecond)
+	for _, name := range []string{"all", "once"} {
+		assert.NoError(t, store.SetCronTime(ctx, group, name, last))
+	}
+
+	m1 := newMonitor()
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	m2 := newMonitor()
+	m2.Start(group)
+	defer m2.Stop()
+
+	uid1, uid2 := m1.(*monitorImpl).uid, m2.(*monitorImpl).uid
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(runs[uid1+":every"]) >= 2
+	}, time.Second, time.Millisecond*10)
+
+	mu.Lock()
+	assert.Equal(t, len(runs[uid2+":every"]), 0) // worker 不执行
+	assert.Equal(t, runs[uid1+":all"], []time.Time{last.Add(time.Minute * 10), last.Add(time.Minute * 20), last.Add(time.Minute * 30)})
+	assert.Equal(t, runs[uid1+":once"], []time.Time{last.Add(time.Minute * 30)})
+	mu.Unlock()
+
+	times, _ := store.CronTimes(ctx, group)
+	assert.Equal(t, times["all"], last.Add(time.Minute*30).UnixMilli())
+}
+
+// 测试执行中失去 master 时取消 fn 的 ctx，并且不再执行剩余计划、不再记录执行时间
+func TestMonitorCronStepDown(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_cron_step_down"
+	ctx := context.TODO()
+	m := NewMonitorWithStore(store).(*monitorImpl)
+	m.group = group
+	defer m.Stop()
+
+	started := make(chan struct{})
+	var runs []time.Time
+	job := &cronJob{name: "cron1", fn: func(ctx context.Context, runAt time.Time) error {
+		runs = append(runs, runAt)
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	last := time.Now().Truncate(time.Millisecond)
+	plan := []time.Time{last.Add(time.Minute), last.Add(time.Minute * 2)}
+
+	m.setMaster(true, nil)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.runCron(job, plan, plan[1])
+	}()
+	<-started
+	m.setMaster(false, ErrMasterLost)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runCron not canceled")
+	}
+	assert.Equal(t, runs, plan[:1])
+	times, _ := store.CronTimes(ctx, group)
+	assert.Equal(t, len(times), 0)
+
+	// 不是 master 时不执行
+	m.runCron(job, plan, plan[1])
+	assert.Equal(t, runs, plan[:1])
+}
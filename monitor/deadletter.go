@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrDeadLetterNotFound 任务不在死信列表中
+	ErrDeadLetterNotFound = errors.New("[Monitor] dead letter not found")
+	// ErrTooManyAttempts 重入次数超过 CallOpt.MaxAttempts，任务未执行直接移入死信列表
+	ErrTooManyAttempts = errors.New("[Monitor] too many reentry attempts")
+)
+
+// DeadLetter 超过最大重入次数的任务
+// 上下文在移入死信列表时改为永不过期，RequeueDeadLetter 时恢复
+type DeadLetter struct {
+	Key      string    `json:"key"` // group|method|tag
+	Method   string    `json:"method"`
+	Tag      string    `json:"tag"`
+	Owner    string    `json:"owner"`    // 最后一次重入的节点
+	Attempts int64     `json:"attempts"` // 重入次数
+	Error    string    `json:"error"`    // 最后一次重入的错误
+	Time     time.Time `json:"time"`     // 移入死信列表的时间
+}
+
+// DeadLetters 死信列表，按 key 排序
+func (m *monitorImpl) DeadLetters(ctx context.Context) (list []DeadLetter, err error) {
+	letters, err := m.store.DeadLetters(ctx, m.group)
+	if err != nil {
+		return nil, errors.Wrap(err, "[Monitor] DeadLetters Error")
+	}
+	list = make([]DeadLetter, 0, len(letters))
+	for key, body := range letters {
+		var letter DeadLetter
+		if err := json.Unmarshal(body, &letter); err != nil {
+			log.Printf("[Monitor] DeadLetters Unmarshal key: %s, Error: %v", key, err)
+		}
+		letter.Key = key
+		_, letter.Method, letter.Tag, _ = ParseKey(key)
+		list = append(list, letter)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return
+}
+
+// RequeueDeadLetter 将死信重新加入任务列表，由 master 在下一次心跳时重入，重入次数从 0 开始
+func (m *monitorImpl) RequeueDeadLetter(ctx context.Context, method string, tag string) error {
+	key := m.watchKey(method, tag)
+	if err := m.checkDeadLetter(ctx, key); err != nil {
+		return err
+	}
+	// 恢复上下文时长
+	body, err := m.store.GetContext(ctx, key)
+	if err != nil && !errors.Is(err, ErrNil) {
+		return errors.Wrap(err, "[Monitor] RequeueDeadLetter GetContext Error")
+	}
+	if err == nil {
+		if err = m.store.SetContext(ctx, key, body, m.watchTimeout); err != nil {
+			return errors.Wrap(err, "[Monitor] RequeueDeadLetter SetContext Error")
+		}
+	}
+	if err = m.store.AddWatch(ctx, m.group, key, orphanUID, m.clock.Now().Unix()); err != nil {
+		return errors.Wrap(err, "[Monitor] RequeueDeadLetter AddWatch Error")
+	}
+	err = m.store.RemoveDeadLetter(ctx, m.group, key)
+	return errors.Wrap(err, "[Monitor] RequeueDeadLetter Error")
+}
+
+// PurgeDeadLetter 从死信列表删除，同时清理上下文
+func (m *monitorImpl) PurgeDeadLetter(ctx context.Context, method string, tag string) error {
+	key := m.watchKey(method, tag)
+	if err := m.checkDeadLetter(ctx, key); err != nil {
+		return err
+	}
+	if err := m.store.RemoveDeadLetter(ctx, m.group, key); err != nil {
+		return errors.Wrap(err, "[Monitor] PurgeDeadLetter Error")
+	}
+	err := m.store.DelContext(ctx, key)
+	return errors.Wrap(err, "[Monitor] PurgeDeadLetter DelContext Error")
+}
+
+// checkDeadLetter 检查任务是否在死信列表中
+func (m *monitorImpl) checkDeadLetter(ctx context.Context, key string) error {
+	letters, err := m.store.DeadLetters(ctx, m.group)
+	if err != nil {
+		return errors.Wrap(err, "[Monitor] DeadLetters Error")
+	}
+	if _, has := letters[key]; !has {
+		return errors.Wrapf(ErrDeadLetterNotFound, "key: %s", key)
+	}
+	return nil
+}
+
+// deadLetter 超过最大重入次数，从任务列表移入死信列表并预警
+func (m *monitorImpl) deadLetter(key string, attempts int64, cause error) {
+	// 上下文改为永不过期，供 RequeueDeadLetter 使用
+	body, err := m.store.GetContext(m.ctx, key)
+	if err == nil {
+		err = m.store.SetContext(m.ctx, key, body, 0)
+	}
+	if err != nil && !errors.Is(err, ErrNil) {
+		log.Printf("[Monitor] deadLetter persist context key: %s, Error: %v", key, err)
+	}
+
+	letter := DeadLetter{Owner: m.uid, Attempts: attempts, Time: m.clock.Now()}
+	if cause != nil {
+		letter.Error = cause.Error()
+	}
+	data, _ := json.Marshal(letter)
+	if err = m.store.AddDeadLetter(m.ctx, m.group, key, data); err != nil {
+		log.Printf("[Monitor] deadLetter key: %s, Error: %v", key, err)
+		return
+	}
+
+	msg := fmt.Sprintf("[Monitor] reentry failed, move to dead letter, attempts: %d, key: %s, Error: %v", attempts, key, cause)
+	log.Println(msg)
+	m.alert(msg)
+	e := taskEvent(EventDeadLetter, key, m.uid)
+	e.Attempts, e.Err = attempts, cause
+	m.emit(e)
+}
@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试 callback 一直 panic 的任务移入死信列表，以及重新入队与删除
+func TestMonitorDeadLetter(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_dead_letter"
+	ctx := context.TODO()
+	recorder := &eventRecorder{}
+	var panicCount, okCount int64
+	var fixed atomic.Bool
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+		WithEventFunc(recorder.record),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {
+		if !fixed.Load() {
+			atomic.AddInt64(&panicCount, 1)
+			panic("test panic")
+		}
+		atomic.AddInt64(&okCount, 1)
+	}, CallOpt{MaxAttempts: 2})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	// 心跳超时节点遗留的任务
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	for _, tag := range []string{"tag1", "tag2"} {
+		key := BuildKey(group, "test_method", tag)
+		assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+		assert.NoError(t, store.SetContext(ctx, key, []byte(tag), time.Minute))
+	}
+
+	var letters []DeadLetter
+	assert.Eventually(t, func() bool {
+		letters, _ = m1.DeadLetters(ctx)
+		return len(letters) == 2
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, atomic.LoadInt64(&panicCount), int64(4)) // 2 个任务 * 2 次重入
+	assert.Equal(t, []any{letters[0].Method, letters[0].Tag, letters[0].Attempts, letters[0].Owner},
+		[]any{"test_method", "tag1", int64(2), m1.(*monitorImpl).uid})
+	assert.Contains(t, letters[0].Error, "test panic")
+	keys, _ := m1.WatchList()
+	assert.Equal(t, len(keys), 0)
+	e, has := recorder.find(EventDeadLetter)
+	assert.Equal(t, []any{has, e.Attempts}, []any{true, int64(2)})
+
+	// 上下文保留且永不过期
+	ttl, _ := store.ContextTTL(ctx, BuildKey(group, "test_method", "tag1"))
+	assert.Equal(t, ttl, time.Duration(-1))
+
+	// 重新入队后由 master 重入
+	fixed.Store(true)
+	assert.NoError(t, m1.RequeueDeadLetter(ctx, "test_method", "tag1"))
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt64(&okCount) == 1
+	}, time.Second, time.Millisecond*10)
+	assert.Eventually(t, func() bool {
+		keys, _ := m1.WatchList()
+		return len(keys) == 0
+	}, time.Second, time.Millisecond*10)
+
+	// 删除死信与上下文
+	assert.NoError(t, m1.PurgeDeadLetter(ctx, "test_method", "tag2"))
+	_, err := store.GetContext(ctx, BuildKey(group, "test_method", "tag2"))
+	assert.ErrorIs(t, err, ErrNil)
+	letters, _ = m1.DeadLetters(ctx)
+	assert.Equal(t, len(letters), 0)
+	assert.ErrorIs(t, m1.PurgeDeadLetter(ctx, "test_method", "tag2"), ErrDeadLetterNotFound)
+	assert.ErrorIs(t, m1.RequeueDeadLetter(ctx, "test_method", "tag3"), ErrDeadLetterNotFound)
+}
+
+// 测试节点崩溃导致重入中断时，重入次数仍被计入
+func TestMonitorDeadLetterCrash(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_dead_letter_crash"
+	ctx := context.TODO()
+	var count int64
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {
+		atomic.AddInt64(&count, 1)
+	}, CallOpt{MaxAttempts: 3})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	// 已经在其他节点上重入了 3 次，均因节点崩溃中断
+	key := BuildKey(group, "test_method", "tag1")
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+	for i := 0; i < 3; i++ {
+		store.IncrAttempts(ctx, group, key)
+	}
+
+	assert.Eventually(t, func() bool {
+		letters, _ := m1.DeadLetters(ctx)
+		return len(letters) == 1
+	}, time.Second, time.Millisecond*10)
+	letters, _ := m1.DeadLetters(ctx)
+	assert.Equal(t, []any{letters[0].Attempts, letters[0].Error}, []any{int64(3), ErrTooManyAttempts.Error()})
+	assert.Equal(t, atomic.LoadInt64(&count), int64(0))
+}
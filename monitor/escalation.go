@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrWatchCanceled 任务耗时达到 EscalateCancel 级别，被取消
+var ErrWatchCanceled = errors.New("[Monitor] watch canceled: executed too long")
+
+// ErrLeaseExpired 任务租约过期或已被接管，本节点放弃执行
+var ErrLeaseExpired = errors.New("[Monitor] watch canceled: lease expired")
+
+// EscalationAction 长耗时任务升级后的动作
+type EscalationAction int
+
+const (
+	EscalateWarn   EscalationAction = iota // 预警
+	EscalatePage                           // 紧急预警，由业务层在 EventFunc 中按 Action 区分处理
+	EscalateCancel                         // 预警并取消任务，mctx.Done 关闭，重入时 callback 的 ctx 同时取消
+)
+
+var escalationActionNames = map[EscalationAction]string{
+	EscalateWarn:   "warn",
+	EscalatePage:   "page",
+	EscalateCancel: "cancel",
+}
+
+func (a EscalationAction) String() string {
+	if name, has := escalationActionNames[a]; has {
+		return name
+	}
+	return fmt.Sprintf("EscalationAction(%d)", int(a))
+}
+
+// Escalation 长耗时任务的升级级别
+type Escalation struct {
+	After  time.Duration    // 任务开始后的时长
+	Action EscalationAction // 达到该级别时的动作
+	Repeat time.Duration    // 停留在该级别时重复预警的最小间隔，0 表示只预警一次
+}
+
+// SortEscalations 按时长排序，不修改原配置
+func SortEscalations(levels []Escalation) []Escalation {
+	sorted := append([]Escalation(nil), levels...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].After < sorted[j].After })
+	return sorted
+}
+
+// EscalationLevels 方法的升级级别，Escalations 需已按 SortEscalations 排序
+// 未配置 Escalations 时，WatchWarningTime 视为只预警一次的单个级别，为 0 时使用全局的 watchWarningTime
+func (opt CallOpt) EscalationLevels(watchWarningTime time.Duration) []Escalation {
+	if len(opt.Escalations) > 0 {
+		return opt.Escalations
+	}
+	if opt.WatchWarningTime > 0 {
+		watchWarningTime = opt.WatchWarningTime // 方法级长耗时预警
+	}
+	if watchWarningTime == 0 {
+		return nil
+	}
+	return []Escalation{{After: watchWarningTime, Action: EscalateWarn}}
+}
+
+// EscalationState 任务的升级进度，任务开始时为零值
+type EscalationState struct {
+	Level   int       // 已达到的级别，从 1 开始
+	AlertAt time.Time // 最近一次预警时间
+}
+
+// Escalate 检查已执行 cost 的任务是否需要升级，需要预警时更新进度并返回达到的级别
+// 新达到的级别立即预警；停留在同一级别时按 Repeat 限制预警频率。levels 需按 After 排序
+func (s *EscalationState) Escalate(levels []Escalation, cost time.Duration, now time.Time) (level Escalation, fired bool) {
+	reached := 0
+	for i, l := range levels {
+		if cost > l.After {
+			reached = i + 1
+		}
+	}
+	if reached == 0 {
+		return
+	}
+	if l := levels[reached-1]; reached != s.Level || (l.Repeat > 0 && now.Sub(s.AlertAt) >= l.Repeat) {
+		s.Level, s.AlertAt = reached, now
+		return l, true
+	}
+	return
+}
+
+// escalate 检查本地任务是否需要升级，调用方需持有 m.mu
+func (m *monitorImpl) escalate(key string, lw *localWatch, now time.Time) (msg string, e Event, fired bool) {
+	if lw.canceled {
+		return
+	}
+	cost := now.Sub(lw.startAt)
+	level, fired := lw.escalation.Escalate(m.callOptMap[lw.method].EscalationLevels(m.watchWarningTime), cost, now)
+	if !fired {
+		return
+	}
+	reached := lw.escalation.Level
+
+	switch level.Action {
+	case EscalateCancel:
+		lw.canceled = true
+		msg = fmt.Sprintf("[Monitor] cancel executed too long MonitorContext level: %d, cost: %v, key: %s", reached, cost, key)
+		e = taskEvent(EventWatchCanceled, key, m.uid)
+		e.Err = ErrWatchCanceled
+	default:
+		msg = fmt.Sprintf("[Monitor] find executed too long MonitorContext level: %d, action: %s, cost: %v, key: %s", reached, level.Action, cost, key)
+		e = taskEvent(EventLongRunning, key, m.uid)
+	}
+	log.Println(msg)
+	e.StartAt, e.Cost = lw.startAt, cost
+	e.Level, e.Action = reached, level.Action
+	return
+}
+
+// taskContext 重入时传给 callback 的 ctx，Stop 或 mctx 被取消时结束
+func (m *monitorImpl) taskContext(mctx MonitorContext) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(m.cancelCtx)
+	go func() {
+		select {
+		case <-mctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// canceler 可以被取消的 MonitorContext
+type canceler interface {
+	cancel(err error)
+}
+
+// Done 任务被取消时关闭，未取消时阻塞
+// 任务耗时达到 CallOpt.Escalations 中 EscalateCancel 级别或被 Monitor.Cancel 时取消，运行中的代码应尽快返回
+func (c *monitorContext) Done() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	return c.done
+}
+
+// Err 取消原因，未取消时返回 nil
+func (c *monitorContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// cancel 取消任务，重复调用无效
+func (c *monitorContext) cancel(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	c.err = err
+	close(c.done)
+}
@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试长耗时任务逐级升级，停留在同一级别时按 Repeat 限制预警频率，最终取消任务
+func TestMonitorEscalation(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_escalation"
+	recorder := &eventRecorder{}
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+		WithWatchWarningTime(time.Millisecond*10), // 配置了 Escalations 时忽略
+		WithEventFunc(recorder.record),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {}, CallOpt{
+		Escalations: []Escalation{
+			{After: time.Millisecond * 200, Action: EscalateCancel},
+			{After: time.Millisecond * 30, Action: EscalateWarn, Repeat: time.Millisecond * 50},
+			{After: time.Millisecond * 150, Action: EscalatePage},
+		},
+	})
+	m1.Start(group)
+	defer m1.Stop()
+
+	mctx, err := m1.Watch("test_method", "tag1", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, mctx.Err())
+	select {
+	case <-mctx.Done():
+		assert.ErrorIs(t, mctx.Err(), ErrWatchCanceled)
+	case <-time.After(time.Second):
+		t.Fatal("cancel timeout")
+	}
+	time.Sleep(time.Millisecond * 50) // 取消后不再预警
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	counts := make(map[int]int)
+	var canceled []Event
+	for _, e := range recorder.events {
+		switch e.Type {
+		case EventLongRunning:
+			counts[e.Level]++
+			assert.Equal(t, e.Action, []EscalationAction{EscalateWarn, EscalatePage}[e.Level-1])
+		case EventWatchCanceled:
+			canceled = append(canceled, e)
+		}
+	}
+	// 30ms ~ 150ms 之间每 50ms 预警一次
+	assert.Equal(t, counts[1] >= 1 && counts[1] <= 3, true, counts[1])
+	assert.Equal(t, counts[2], 1)
+	assert.Equal(t, len(canceled), 1)
+	assert.Equal(t, []any{canceled[0].Level, canceled[0].Tag, canceled[0].Err}, []any{3, "tag1", ErrWatchCanceled})
+}
+
+// 测试重入任务被取消时，callback 的 ctx 同时取消
+func TestMonitorEscalationReentry(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_escalation_reentry"
+	ctx := context.TODO()
+	done := make(chan error, 1)
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+	)
+	m1.RegisterContext("test_method", func(ctx context.Context, mctx MonitorContext) {
+		<-ctx.Done()
+		done <- mctx.Err()
+	}, CallOpt{
+		Escalations: []Escalation{{After: time.Millisecond * 50, Action: EscalateCancel}},
+	})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	key := BuildKey(group, "test_method", "tag1")
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrWatchCanceled)
+	case <-time.After(time.Second):
+		t.Fatal("cancel timeout")
+	}
+}
+
+// 测试重入任务被取消后返回 error 时移入死信列表，不再重入
+func TestMonitorEscalationReentryDeadLetter(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_escalation_dead_letter"
+	ctx := context.TODO()
+	var calls int32
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+	)
+	m1.RegisterWithError("test_method", func(ctx context.Context, mctx MonitorContext) error {
+		atomic.AddInt32(&calls, 1)
+		<-ctx.Done()
+		return ctx.Err()
+	}, CallOpt{
+		Escalations:    []Escalation{{After: time.Millisecond * 30, Action: EscalateCancel}},
+		ReentryBackoff: time.Millisecond * 10,
+	})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	key := BuildKey(group, "test_method", "tag1")
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+
+	var letters []DeadLetter
+	assert.Eventually(t, func() bool {
+		letters, _ = m1.DeadLetters(ctx)
+		return len(letters) == 1
+	}, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 100) // 不再重入
+	assert.Equal(t, atomic.LoadInt32(&calls), int32(1))
+	assert.Equal(t, []any{letters[0].Key, letters[0].Attempts, letters[0].Error}, []any{key, int64(1), ErrWatchCanceled.Error()})
+	maps, _ := store.WatchList(ctx, group)
+	assert.Equal(t, len(maps), 0)
+}
+
+// 测试 EscalationState 逐级升级与 Repeat 限频，monitor 与 mock.FakeMonitor 共用
+func TestEscalationState(t *testing.T) {
+	levels := CallOpt{Escalations: SortEscalations([]Escalation{
+		{After: time.Minute * 30, Action: EscalateCancel},
+		{After: time.Minute * 10, Action: EscalateWarn, Repeat: time.Minute * 5},
+	})}.EscalationLevels(time.Minute)
+	start := time.Now()
+	s := &EscalationState{}
+	check := func(cost time.Duration) []any {
+		level, fired := s.Escalate(levels, cost, start.Add(cost))
+		return []any{level.Action, fired, s.Level}
+	}
+	assert.Equal(t, check(time.Minute*5), []any{EscalateWarn, false, 0})
+	assert.Equal(t, check(time.Minute*11), []any{EscalateWarn, true, 1})
+	assert.Equal(t, check(time.Minute*14), []any{EscalateWarn, false, 1}) // 未到 Repeat
+	assert.Equal(t, check(time.Minute*17), []any{EscalateWarn, true, 1})
+	assert.Equal(t, check(time.Minute*31), []any{EscalateCancel, true, 2})
+	assert.Equal(t, check(time.Minute*40), []any{EscalateWarn, false, 2}) // Repeat 为 0，只触发一次
+
+	// 未配置 Escalations 时使用方法级或全局的 WatchWarningTime
+	assert.Equal(t, CallOpt{}.EscalationLevels(time.Minute), []Escalation{{After: time.Minute, Action: EscalateWarn}})
+	assert.Equal(t, CallOpt{WatchWarningTime: time.Second}.EscalationLevels(time.Minute), []Escalation{{After: time.Second, Action: EscalateWarn}})
+	assert.Equal(t, len(CallOpt{}.EscalationLevels(0)), 0)
+}
@@ -0,0 +1,128 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrMasterLost master 锁被其他节点抢占或已过期，EventMasterLost 的 Err
+var ErrMasterLost = errors.New("[Monitor] master lock lost")
+
+// EventType monitor 事件类型
+type EventType int
+
+const (
+	EventMasterElected   EventType = iota + 1 // 选举为 master
+	EventMasterLost                           // 失去 master
+	EventNodeJoined                           // 节点加入，由 master 检测
+	EventNodeExpired                          // 节点心跳超时，由 master 检测
+	EventReentryStarted                       // 开始重入任务
+	EventReentryFinished                      // 重入任务结束，Err 为执行结果
+	EventWatchExpired                         // 无效的 mctx 被移除
+	EventLongRunning                          // 长耗时任务预警
+	EventReentryAssigned                      // 重入任务分配给其他节点，Target 为目标节点
+	EventLeaseExpired                         // 本地任务租约过期，放弃执行并等待重入
+	EventDeadLetter                           // 超过最大重入次数，移入死信列表
+	EventScheduleDue                          // 延时任务到期，加入任务列表等待重入
+	EventCronFinished                         // 定时任务执行结束，Method 为任务名，Err 为执行结果
+	EventWatchCanceled                        // 长耗时任务达到取消级别或被 Cancel，mctx 被取消
+)
+
+var eventTypeNames = map[EventType]string{
+	EventMasterElected:   "MasterElected",
+	EventMasterLost:      "MasterLost",
+	EventNodeJoined:      "NodeJoined",
+	EventNodeExpired:     "NodeExpired",
+	EventReentryStarted:  "ReentryStarted",
+	EventReentryFinished: "ReentryFinished",
+	EventWatchExpired:    "WatchExpired",
+	EventLongRunning:     "LongRunning",
+	EventReentryAssigned: "ReentryAssigned",
+	EventLeaseExpired:    "LeaseExpired",
+	EventDeadLetter:      "DeadLetter",
+	EventScheduleDue:     "ScheduleDue",
+	EventCronFinished:    "CronFinished",
+	EventWatchCanceled:   "WatchCanceled",
+}
+
+func (t EventType) String() string {
+	if name, has := eventTypeNames[t]; has {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event monitor 事件
+// 节点类事件 Owner 为对应节点；任务类事件 Owner 为任务所属节点
+type Event struct {
+	Type     EventType
+	Group    string
+	Method   string
+	Tag      string
+	Key      string           // group|method|tag
+	UID      string           // 产生事件的节点
+	Owner    string           // 事件关联的节点
+	Target   string           // 任务分配的目标节点
+	Time     time.Time        // 事件时间
+	StartAt  time.Time        // 任务开始时间；节点事件为最近一次心跳时间
+	Cost     time.Duration    // 任务耗时；节点事件为距离最近一次心跳的时长
+	Attempts int64            // 重入次数，包含本次
+	Level    int              // 长耗时任务的升级级别，从 1 开始
+	Action   EscalationAction // 长耗时任务升级后的动作
+	Err      error
+}
+
+func (e Event) String() string {
+	msg := fmt.Sprintf("[Monitor] event: %s, group: %s, uid: %s", e.Type, e.Group, e.UID)
+	if e.Key != "" {
+		msg += ", key: " + e.Key
+	}
+	if e.Owner != "" {
+		msg += ", owner: " + e.Owner
+	}
+	if e.Target != "" {
+		msg += ", target: " + e.Target
+	}
+	if e.Cost > 0 {
+		msg += fmt.Sprintf(", cost: %v", e.Cost)
+	}
+	if e.Level > 0 {
+		msg += fmt.Sprintf(", level: %d, action: %s", e.Level, e.Action)
+	}
+	if e.Err != nil {
+		msg += fmt.Sprintf(", error: %v", e.Err)
+	}
+	return msg
+}
+
+// EventFunc 事件回调
+// 在 monitor 内部 goroutine 中同步调用，不应阻塞
+type EventFunc func(e Event)
+
+// WithEventFunc 订阅 monitor 事件，可以多次设置
+func WithEventFunc(fn EventFunc) MOpt {
+	return func(r *monitorImpl) {
+		r.eventFuncs = append(r.eventFuncs, fn)
+	}
+}
+
+// emit 发送事件，调用方不能持有 m.mu
+func (m *monitorImpl) emit(e Event) {
+	e.Group = m.group
+	e.UID = m.uid
+	if e.Time.IsZero() {
+		e.Time = m.clock.Now()
+	}
+	for _, fn := range m.eventFuncs {
+		fn(e)
+	}
+}
+
+// taskEvent 构建任务类事件
+func taskEvent(typ EventType, key string, owner string) Event {
+	e := Event{Type: typ, Key: key, Owner: owner}
+	_, e.Method, e.Tag, _ = ParseKey(key)
+	return e
+}
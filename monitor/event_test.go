@@ -0,0 +1,140 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) find(typ EventType) (e Event, has bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e = range r.events {
+		if e.Type == typ {
+			return e, true
+		}
+	}
+	return
+}
+
+func TestMonitorEvent(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_event"
+	ctx := context.TODO()
+	recorder := &eventRecorder{}
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+		WithWatchWarningTime(time.Millisecond*20),
+		WithEventFunc(recorder.record),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {})
+	m1.Start(group)
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	// 心跳超时的节点，及其遗留的任务
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	assert.NoError(t, store.AddWatch(ctx, group, group+"|test_method|1", "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, group+"|test_method|1", nil, time.Minute))
+	// 没有上下文的任务
+	assert.NoError(t, store.AddWatch(ctx, group, group+"|test_method|2", "ghost", time.Now().Unix()))
+	// 新节点加入
+	assert.NoError(t, store.Heartbeat(ctx, group, "joined", time.Now().Unix()))
+	// 长耗时任务
+	_, err := m1.Watch("test_method", "3", nil)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, has := recorder.find(EventLongRunning)
+		return has
+	}, time.Second, time.Millisecond*10)
+	m1.Stop()
+
+	e, has := recorder.find(EventMasterElected)
+	assert.Equal(t, has, true)
+	assert.Equal(t, e.Group, group)
+	assert.Equal(t, e.Owner, e.UID)
+
+	e, has = recorder.find(EventNodeJoined)
+	assert.Equal(t, []any{has, e.Owner}, []any{true, "joined"})
+
+	e, has = recorder.find(EventNodeExpired)
+	assert.Equal(t, []any{has, e.Owner, e.Cost >= time.Minute}, []any{true, "ghost", true})
+
+	e, has = recorder.find(EventReentryStarted)
+	assert.Equal(t, []any{has, e.Method, e.Tag, e.Owner}, []any{true, "test_method", "1", "ghost"})
+
+	e, has = recorder.find(EventReentryFinished)
+	assert.Equal(t, []any{has, e.Tag, e.Err}, []any{true, "1", nil})
+
+	e, has = recorder.find(EventWatchExpired)
+	assert.Equal(t, []any{has, e.Tag}, []any{true, "2"})
+
+	e, has = recorder.find(EventLongRunning)
+	assert.Equal(t, []any{has, e.Tag, e.Cost > time.Millisecond*20}, []any{true, "3", true})
+
+	_, has = recorder.find(EventMasterLost)
+	assert.Equal(t, has, true)
+}
+
+// 测试 master 锁丢失后降级
+func TestMonitorMasterLost(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_master_lost"
+	recorder := &eventRecorder{}
+	leader := make(chan bool, 10)
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithEventFunc(recorder.record),
+		WithLeaderFunc(func(isMaster bool) { leader <- isMaster }),
+	)
+	m1.Start(group)
+	defer m1.Stop()
+	assert.Equal(t, <-leader, true)
+	assert.Equal(t, m1.IsMaster(), true)
+
+	// 模拟网络分区：锁过期后被其他节点抢占
+	other := store.NewLocker()
+	_, err := other.UnlockForce(group)
+	assert.NoError(t, err)
+	success, _ := other.Lock(group)
+	assert.Equal(t, success, true)
+
+	select {
+	case isMaster := <-leader:
+		assert.Equal(t, isMaster, false)
+	case <-time.After(time.Second):
+		t.Fatal("step down timeout")
+	}
+	assert.Equal(t, m1.IsMaster(), false)
+	e, has := recorder.find(EventMasterLost)
+	assert.Equal(t, []any{has, e.Err}, []any{true, ErrMasterLost})
+
+	// 锁释放后重新选举
+	other.Unlock()
+	select {
+	case isMaster := <-leader:
+		assert.Equal(t, isMaster, true)
+	case <-time.After(time.Second):
+		t.Fatal("election timeout")
+	}
+	assert.Equal(t, m1.IsMaster(), true)
+}
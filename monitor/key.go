@@ -0,0 +1,328 @@
+package monitor
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/FredyXue/go-utils/monitor/locker"
+)
+
+// 任务 key 的格式为 group|method|tag
+// 各段中的 \ 转义为 \\，| 转义为 \|，tag 中可以包含任意字符
+// 不含 \ 与 | 的 key 与旧版本保持一致
+
+const (
+	keySep    = '|'
+	keyEscape = '\\'
+)
+
+var keyEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+
+// escapeKey 转义 key 中的一段
+func escapeKey(part string) string {
+	return keyEscaper.Replace(part)
+}
+
+// BuildKey 构建任务 key，Admin、测试等需要与 monitor 一致的 key 时使用
+func BuildKey(group string, method string, tag string) string {
+	return escapeKey(group) + string(keySep) + escapeKey(method) + string(keySep) + escapeKey(tag)
+}
+
+// splitKey 解析任务 key，返回反转义后的各段
+func splitKey(key string) []string {
+	var arr []string
+	var b strings.Builder
+	escaped := false
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case escaped:
+			b.WriteByte(c)
+			escaped = false
+		case c == keyEscape:
+			escaped = true
+		case c == keySep:
+			arr = append(arr, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(arr, b.String())
+}
+
+// ParseKey 解析任务 key，格式不正确时 ok 为 false
+func ParseKey(key string) (group string, method string, tag string, ok bool) {
+	arr := splitKey(key)
+	if len(arr) != 3 {
+		return
+	}
+	return arr[0], arr[1], arr[2], true
+}
+
+// keyGroupEnd 第一个未转义的分隔符位置，不存在返回 -1
+func keyGroupEnd(key string) int {
+	for i := 0; i < len(key); i++ {
+		switch key[i] {
+		case keyEscape:
+			i++
+		case keySep:
+			return i
+		}
+	}
+	return -1
+}
+
+// WithKeyPrefix 为所有存储的 key 增加前缀，用于多租户隔离
+// 前缀相同的 Monitor 才能互相看到节点与任务，Admin 需使用 NewPrefixStore 包装相同的前缀
+// 前缀在任务 key 中按 BuildKey 的规则转义，可以包含 | 与 \
+func WithKeyPrefix(prefix string) MOpt {
+	return func(r *monitorImpl) {
+		r.keyPrefix = prefix
+	}
+}
+
+// prefixStore 为 group、任务 key 与选举锁增加前缀
+// 任务 key 在存储中为 escapeKey(prefix)+key，与 BuildKey(prefix+group, method, tag) 一致，读取任务列表时去掉前缀
+type prefixStore struct {
+	Store
+	prefix    string
+	keyPrefix string // 转义后的前缀，用于任务 key
+}
+
+// NewPrefixStore 包装存储后端，为所有 key 增加前缀，prefix 为空时原样返回
+func NewPrefixStore(store Store, prefix string) Store {
+	if prefix == "" {
+		return store
+	}
+	return &prefixStore{Store: store, prefix: prefix, keyPrefix: escapeKey(prefix)}
+}
+
+func (s *prefixStore) group(group string) string {
+	return s.prefix + group
+}
+
+func (s *prefixStore) key(key string) string {
+	return s.keyPrefix + key
+}
+
+// trim 去掉任务 key 的前缀，不带前缀的 key 不属于当前命名空间
+func (s *prefixStore) trim(key string) (string, bool) {
+	if !strings.HasPrefix(key, s.keyPrefix) {
+		return "", false
+	}
+	return key[len(s.keyPrefix):], true
+}
+
+// trimKeys 去掉前缀，忽略不属于当前命名空间的 key
+func (s *prefixStore) trimKeys(keys []string) []string {
+	list := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if k, ok := s.trim(key); ok {
+			list = append(list, k)
+		}
+	}
+	return list
+}
+
+func (s *prefixStore) Heartbeat(ctx context.Context, group string, uid string, timestamp int64) error {
+	return s.Store.Heartbeat(ctx, s.group(group), uid, timestamp)
+}
+
+func (s *prefixStore) NodeList(ctx context.Context, group string) (map[string]int64, error) {
+	return s.Store.NodeList(ctx, s.group(group))
+}
+
+func (s *prefixStore) RemoveNode(ctx context.Context, group string, uid string) error {
+	return s.Store.RemoveNode(ctx, s.group(group), uid)
+}
+
+func (s *prefixStore) AddWatch(ctx context.Context, group string, key string, uid string, timestamp int64) error {
+	return s.Store.AddWatch(ctx, s.group(group), s.key(key), uid, timestamp)
+}
+
+func (s *prefixStore) RemoveWatch(ctx context.Context, group string, key string) error {
+	return s.Store.RemoveWatch(ctx, s.group(group), s.key(key))
+}
+
+func (s *prefixStore) RemoveWatchIf(ctx context.Context, group string, key string, uid string) (bool, error) {
+	return s.Store.RemoveWatchIf(ctx, s.group(group), s.key(key), uid)
+}
+
+func (s *prefixStore) WatchList(ctx context.Context, group string) (map[string]string, error) {
+	maps, err := s.Store.WatchList(ctx, s.group(group))
+	if err != nil {
+		return nil, err
+	}
+	return trimMap(s, maps), nil
+}
+
+func (s *prefixStore) WatchTimes(ctx context.Context, group string) (map[string]int64, error) {
+	times, err := s.Store.WatchTimes(ctx, s.group(group))
+	if err != nil {
+		return nil, err
+	}
+	return trimMap(s, times), nil
+}
+
+func (s *prefixStore) IncrAttempts(ctx context.Context, group string, key string) (int64, error) {
+	return s.Store.IncrAttempts(ctx, s.group(group), s.key(key))
+}
+
+func (s *prefixStore) AddSchedule(ctx context.Context, group string, key string, runAt time.Time, body []byte) error {
+	return s.Store.AddSchedule(ctx, s.group(group), s.key(key), runAt, body)
+}
+
+func (s *prefixStore) DueSchedules(ctx context.Context, group string, now time.Time) (map[string][]byte, error) {
+	schedules, err := s.Store.DueSchedules(ctx, s.group(group), now)
+	if err != nil {
+		return nil, err
+	}
+	return trimMap(s, schedules), nil
+}
+
+func (s *prefixStore) RemoveSchedule(ctx context.Context, group string, key string) error {
+	return s.Store.RemoveSchedule(ctx, s.group(group), s.key(key))
+}
+
+func (s *prefixStore) CronTimes(ctx context.Context, group string) (map[string]int64, error) {
+	return s.Store.CronTimes(ctx, s.group(group))
+}
+
+func (s *prefixStore) SetCronTime(ctx context.Context, group string, name string, runAt time.Time) error {
+	return s.Store.SetCronTime(ctx, s.group(group), name, runAt)
+}
+
+func (s *prefixStore) PublishCancel(ctx context.Context, group string, key string) error {
+	return s.Store.PublishCancel(ctx, s.group(group), s.key(key))
+}
+
+func (s *prefixStore) SubscribeCancel(ctx context.Context, group string, fn func(key string)) (<-chan struct{}, error) {
+	return s.Store.SubscribeCancel(ctx, s.group(group), func(key string) {
+		if k, ok := s.trim(key); ok {
+			fn(k)
+		}
+	})
+}
+
+func (s *prefixStore) AddDeadLetter(ctx context.Context, group string, key string, body []byte) error {
+	return s.Store.AddDeadLetter(ctx, s.group(group), s.key(key), body)
+}
+
+func (s *prefixStore) DeadLetters(ctx context.Context, group string) (map[string][]byte, error) {
+	letters, err := s.Store.DeadLetters(ctx, s.group(group))
+	if err != nil {
+		return nil, err
+	}
+	return trimMap(s, letters), nil
+}
+
+func (s *prefixStore) RemoveDeadLetter(ctx context.Context, group string, key string) error {
+	return s.Store.RemoveDeadLetter(ctx, s.group(group), s.key(key))
+}
+
+func (s *prefixStore) ClaimWatch(ctx context.Context, group string, key string, from string, to string) (bool, error) {
+	return s.Store.ClaimWatch(ctx, s.group(group), s.key(key), from, to)
+}
+
+func (s *prefixStore) ReleaseWatch(ctx context.Context, group string, key string, uid string, retryAt time.Time) (bool, error) {
+	return s.Store.ReleaseWatch(ctx, s.group(group), s.key(key), uid, retryAt)
+}
+
+func (s *prefixStore) DelayedWatches(ctx context.Context, group string, now time.Time) ([]string, error) {
+	keys, err := s.Store.DelayedWatches(ctx, s.group(group), now)
+	if err != nil {
+		return nil, err
+	}
+	return s.trimKeys(keys), nil
+}
+
+func (s *prefixStore) SetLease(ctx context.Context, group string, key string, expireAt time.Time) error {
+	return s.Store.SetLease(ctx, s.group(group), s.key(key), expireAt)
+}
+
+func (s *prefixStore) ExpiredLeases(ctx context.Context, group string, now time.Time) ([]string, error) {
+	keys, err := s.Store.ExpiredLeases(ctx, s.group(group), now)
+	if err != nil {
+		return nil, err
+	}
+	return s.trimKeys(keys), nil
+}
+
+func (s *prefixStore) Assign(ctx context.Context, group string, key string, from string, to string) (bool, error) {
+	return s.Store.Assign(ctx, s.group(group), s.key(key), from, to)
+}
+
+func (s *prefixStore) Assignments(ctx context.Context, group string, uid string) (map[string]string, error) {
+	assigns, err := s.Store.Assignments(ctx, s.group(group), uid)
+	if err != nil {
+		return nil, err
+	}
+	return trimMap(s, assigns), nil
+}
+
+func (s *prefixStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	return s.Store.GetContext(ctx, s.key(key))
+}
+
+func (s *prefixStore) SetContext(ctx context.Context, key string, body []byte, expiration time.Duration) error {
+	return s.Store.SetContext(ctx, s.key(key), body, expiration)
+}
+
+func (s *prefixStore) ContextTTL(ctx context.Context, key string) (time.Duration, error) {
+	return s.Store.ContextTTL(ctx, s.key(key))
+}
+
+func (s *prefixStore) DelContext(ctx context.Context, key string) error {
+	return s.Store.DelContext(ctx, s.key(key))
+}
+
+func (s *prefixStore) SetStep(ctx context.Context, key string, step string, body []byte, expiration time.Duration) error {
+	return s.Store.SetStep(ctx, s.key(key), step, body, expiration)
+}
+
+func (s *prefixStore) GetSteps(ctx context.Context, key string) (map[string][]byte, error) {
+	return s.Store.GetSteps(ctx, s.key(key))
+}
+
+func (s *prefixStore) SetMaster(ctx context.Context, group string, uid string, expiration time.Duration) error {
+	return s.Store.SetMaster(ctx, s.group(group), uid, expiration)
+}
+
+func (s *prefixStore) GetMaster(ctx context.Context, group string) (string, error) {
+	return s.Store.GetMaster(ctx, s.group(group))
+}
+
+func (s *prefixStore) NewLocker(opts ...locker.Option) locker.Locker {
+	return &prefixLocker{Locker: s.Store.NewLocker(opts...), prefix: s.prefix}
+}
+
+// trimMap 去掉 map 中任务 key 的前缀
+func trimMap[V any](s *prefixStore, maps map[string]V) map[string]V {
+	rlt := make(map[string]V, len(maps))
+	for key, v := range maps {
+		if k, ok := s.trim(key); ok {
+			rlt[k] = v
+		}
+	}
+	return rlt
+}
+
+// prefixLocker 为锁的 key 增加前缀
+type prefixLocker struct {
+	locker.Locker
+	prefix string
+}
+
+func (l *prefixLocker) Lock(key string) (bool, error) {
+	return l.Locker.Lock(l.prefix + key)
+}
+
+func (l *prefixLocker) UnlockForce(key string) (bool, error) {
+	return l.Locker.UnlockForce(l.prefix + key)
+}
+
+func (l *prefixLocker) Check(key string) (bool, bool, error) {
+	return l.Locker.Check(l.prefix + key)
+}
@@ -0,0 +1,212 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FredyXue/go-utils/testdata"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyEncoding(t *testing.T) {
+	cases := [][3]string{
+		{"group", "method", "tag"},
+		{"group", "method", "a|b|c"},
+		{"g|1", "m\\", "\\|t\\"},
+		{"group", "method", ""},
+	}
+	for _, c := range cases {
+		key := BuildKey(c[0], c[1], c[2])
+		group, method, tag, ok := ParseKey(key)
+		assert.Equal(t, []any{group, method, tag, ok}, []any{c[0], c[1], c[2], true}, key)
+	}
+	assert.Equal(t, BuildKey("group", "method", "tag"), "group|method|tag") // 与旧版本一致
+	assert.Equal(t, BuildKey("group", "method", "a|b"), `group|method|a\|b`)
+	assert.Equal(t, keyGroupEnd(`g\|1|m|t`), 4)
+
+	_, _, _, ok := ParseKey("group|method")
+	assert.Equal(t, ok, false)
+	_, _, _, ok = ParseKey("group|method|a|b")
+	assert.Equal(t, ok, false)
+}
+
+// 测试 tag 中包含 | 的任务重入，以及不同前缀的 monitor 互相隔离
+func TestMonitorKeyPrefix(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_key_prefix"
+	ctx := context.TODO()
+	done := make(chan string, 10)
+
+	newMonitor := func(prefix string) Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Second),
+			WithWatchTimeout(time.Minute),
+			WithKeyPrefix(prefix),
+		)
+		m.Register("test_method", func(mctx MonitorContext) {
+			body, _ := mctx.Get()
+			done <- prefix + string(body)
+		})
+		m.Start(group)
+		return m
+	}
+	m1 := newMonitor("tenant1:")
+	defer m1.Stop()
+	m2 := newMonitor("tenant2:")
+	defer m2.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待选举完成，两个 monitor 都是 master
+
+	assert.Equal(t, []any{m1.IsMaster(), m2.IsMaster()}, []any{true, true})
+
+	// tenant1 中心跳超时节点遗留的任务
+	tenant1 := NewPrefixStore(store, "tenant1:")
+	key := BuildKey(group, "test_method", "order|1001")
+	assert.NoError(t, tenant1.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	assert.NoError(t, tenant1.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, tenant1.SetContext(ctx, key, []byte("body"), time.Minute))
+
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "tenant1:body")
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, len(done), 0)
+
+	// 存储中的 key 带有前缀
+	maps, _ := store.WatchList(ctx, "tenant1:"+group)
+	assert.Equal(t, len(maps), 0)
+	_, err := store.GetContext(ctx, "tenant1:"+key)
+	assert.ErrorIs(t, err, ErrNil)
+
+	_, err = m2.Watch("test_method", "a|b", nil)
+	assert.NoError(t, err)
+	keys, _ := m2.WatchList()
+	assert.Equal(t, keys, []string{group + `|test_method|a\|b`})
+	keys, _ = m1.WatchList()
+	assert.Equal(t, len(keys), 0)
+}
+
+// 测试前缀中的 | 与 \ 在任务 key 中转义，存储中的 key 仍可以解析
+func TestPrefixStoreEscape(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.TODO()
+	group := "test_prefix_escape"
+	prefix := `t|1\`
+	tenant := NewPrefixStore(store, prefix)
+	key := BuildKey(group, "test_method", "tag1")
+
+	assert.NoError(t, tenant.AddWatch(ctx, group, key, "uid1", time.Now().Unix()))
+	maps, _ := store.WatchList(ctx, prefix+group)
+	assert.Equal(t, len(maps), 1)
+	for k := range maps {
+		g, method, tag, ok := ParseKey(k)
+		assert.Equal(t, []any{g, method, tag, ok}, []any{prefix + group, "test_method", "tag1", true})
+		assert.Equal(t, k, BuildKey(prefix+group, "test_method", "tag1"))
+	}
+	maps, _ = tenant.WatchList(ctx, group)
+	assert.Equal(t, maps, map[string]string{key: "uid1"})
+}
+
+func TestMigrateKeys(t *testing.T) {
+	cli := testdata.NewTestRedis()
+	ctx := context.TODO()
+	group := "test_migrate"
+	key := group + "|test_method|a|b" // 旧版本未转义
+
+	cli.ZAdd(ctx, group+":List", &redis.Z{Score: 100, Member: "uid1"})
+	cli.HSet(ctx, group+":WatchList", key, "uid1")
+	cli.HSet(ctx, group+":WatchTime", key, 100)
+	cli.HSet(ctx, group+":Attempts", key, 2)
+	cli.ZAdd(ctx, group+":Lease", &redis.Z{Score: 200, Member: key})
+	cli.HSet(ctx, group+":Assign:uid1", key, "uid0")
+	cli.Set(ctx, group+":Master", "uid1", time.Minute)
+	cli.Set(ctx, key, "body", time.Minute)
+	cli.HSet(ctx, key+":Steps", "step1", "done")
+
+	count, err := MigrateKeys(ctx, cli, group, "tenant1:")
+	assert.NoError(t, err)
+	assert.Equal(t, count, 1)
+
+	store := NewPrefixStore(NewRedisStore(cli), "tenant1:")
+	newKey := BuildKey(group, "test_method", "a|b")
+	nodes, _ := store.NodeList(ctx, group)
+	assert.Equal(t, nodes, map[string]int64{"uid1": 100})
+	maps, _ := store.WatchList(ctx, group)
+	assert.Equal(t, maps, map[string]string{newKey: "uid1"})
+	times, _ := store.WatchTimes(ctx, group)
+	assert.Equal(t, times, map[string]int64{newKey: 100})
+	attempts, _ := store.IncrAttempts(ctx, group, newKey)
+	assert.Equal(t, attempts, int64(3))
+	leases, _ := store.ExpiredLeases(ctx, group, time.Now())
+	assert.Equal(t, leases, []string{newKey})
+	assigns, _ := store.Assignments(ctx, group, "uid1")
+	assert.Equal(t, assigns, map[string]string{newKey: "uid0"})
+	body, _ := store.GetContext(ctx, newKey)
+	assert.Equal(t, string(body), "body")
+	ttl, _ := store.ContextTTL(ctx, newKey)
+	assert.Equal(t, ttl > 0, true)
+	steps, _ := store.GetSteps(ctx, newKey)
+	assert.Equal(t, steps, map[string][]byte{"step1": []byte("done")})
+
+	// 旧 key 全部删除
+	keys, _ := cli.Keys(ctx, group+"*").Result()
+	assert.Equal(t, len(keys), 0)
+
+	// 重复执行
+	count, err = MigrateKeys(ctx, cli, group, "tenant1:")
+	assert.Equal(t, []any{count, err}, []any{0, nil})
+}
+
+// 滚动升级期间旧版本节点遗留的任务由新版本的 master 接管
+func TestMonitorAdoptLegacy(t *testing.T) {
+	cli := testdata.NewTestRedis()
+	ctx := context.TODO()
+	group := "test_adopt_legacy"
+	deadKey := group + "|test_method|a|b" // 旧版本未转义
+	aliveKey := group + "|test_method|c"
+	done := make(chan string, 1)
+
+	cli.ZAdd(ctx, group+":List", &redis.Z{Score: float64(time.Now().Add(-time.Minute).Unix()), Member: "old1"})
+	cli.ZAdd(ctx, group+":List", &redis.Z{Score: float64(time.Now().Unix()), Member: "old2"})
+	cli.HSet(ctx, group+":WatchList", deadKey, "old1")
+	cli.HSet(ctx, group+":WatchList", aliveKey, "old2")
+	cli.Set(ctx, deadKey, "body", time.Minute)
+
+	m1 := NewMonitorWithStore(NewRedisStore(cli),
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {
+		body, _ := mctx.Get()
+		done <- string(body)
+	})
+	m1.Start(group)
+	defer m1.Stop()
+
+	select {
+	case s := <-done:
+		assert.Equal(t, s, "body")
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+	// 存活的旧版本节点的任务保留在旧任务列表中
+	maps, _ := cli.HGetAll(ctx, group+":WatchList").Result()
+	assert.Equal(t, maps, map[string]string{aliveKey: "old2"})
+	n, _ := cli.Exists(ctx, deadKey).Result()
+	assert.Equal(t, n, int64(0))
+
+	// 旧版本节点全部下线、旧任务列表为空后，不再检查
+	cli.HDel(ctx, group+":WatchList", aliveKey)
+	cli.ZRem(ctx, group+":List", "old2")
+	time.Sleep(time.Millisecond * 50)
+	lateKey := group + "|test_method|d"
+	cli.HSet(ctx, group+":WatchList", lateKey, "old1")
+	time.Sleep(time.Millisecond * 50)
+	maps, _ = cli.HGetAll(ctx, group+":WatchList").Result()
+	assert.Equal(t, maps, map[string]string{lateKey: "old1"})
+}
@@ -0,0 +1,65 @@
+package monitor
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// leaseHolder 携带租约的 MonitorContext
+type leaseHolder interface {
+	leaseExpired() bool
+}
+
+// Renew 续约任务租约，未配置 CallOpt.LeaseTTL 或已 Close 时不做任何操作
+func (c *monitorContext) Renew() error {
+	c.mu.Lock()
+	closed := c.closed
+	c.mu.Unlock()
+	if c.leaseTTL <= 0 || closed {
+		return nil
+	}
+
+	expireAt := c.clock.Now().Add(c.leaseTTL)
+	if err := c.store.SetLease(c.ctx, c.group, c.key, expireAt); err != nil {
+		return errors.Wrap(err, "[MonitorContext] Renew Error")
+	}
+	c.mu.Lock()
+	c.leaseExpire = expireAt
+	c.mu.Unlock()
+	return nil
+}
+
+// KeepAlive 每 LeaseTTL/3 续约一次，直到 ctx 结束或 mctx 被 Close
+// 续约失败时返回 error，租约可能已丢失，业务层可以据此中断任务
+// 未配置 CallOpt.LeaseTTL 时直接返回
+func (c *monitorContext) KeepAlive(ctx context.Context) error {
+	if c.leaseTTL <= 0 {
+		return nil
+	}
+	ticker := c.clock.NewTicker(c.leaseTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			c.mu.Lock()
+			closed := c.closed
+			c.mu.Unlock()
+			if closed {
+				return nil
+			}
+			if err := c.Renew(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// leaseExpired 本地记录的租约是否已过期
+func (c *monitorContext) leaseExpired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.leaseTTL > 0 && !c.leaseExpire.IsZero() && c.clock.Now().After(c.leaseExpire)
+}
@@ -0,0 +1,134 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试节点存活但任务卡住时，租约过期触发重入
+func TestMonitorLease(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_lease"
+	recorder := &eventRecorder{}
+	done := make(chan string, 10)
+
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Minute), // 节点不会心跳超时
+			WithWatchTimeout(time.Minute),
+			WithEventFunc(recorder.record),
+		)
+		m.Register("test_method", func(mctx MonitorContext) {
+			body, _ := mctx.Get()
+			done <- string(body)
+		}, CallOpt{LeaseTTL: time.Millisecond * 60})
+		m.Start(group)
+		return m
+	}
+
+	m1 := newMonitor()
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	m2 := newMonitor()
+	defer m2.Stop()
+
+	// 持续续约的任务不会被重入
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	alive, err := m2.Watch("test_method", "alive", []byte("alive"))
+	assert.NoError(t, err)
+	go alive.KeepAlive(ctx)
+
+	// 卡住的任务，不再续约
+	stuck, err := m2.Watch("test_method", "stuck", []byte("stuck"))
+	assert.NoError(t, err)
+
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "stuck")
+	case <-time.After(time.Second):
+		t.Fatal("lease reentry timeout")
+	}
+	e, has := recorder.find(EventLeaseExpired)
+	assert.Equal(t, []any{has, e.Tag, e.UID}, []any{true, "stuck", m2.(*monitorImpl).uid})
+	select {
+	case <-stuck.Done(): // 放弃时取消原任务
+		assert.ErrorIs(t, stuck.Err(), ErrLeaseExpired)
+	case <-time.After(time.Second):
+		t.Fatal("abandon cancel timeout")
+	}
+
+	time.Sleep(time.Millisecond * 100)
+	assert.Equal(t, len(done), 0)
+	keys, _ := m1.WatchList()
+	assert.Equal(t, keys, []string{group + "|test_method|alive"})
+
+	// 停止续约后重入
+	cancel()
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "alive")
+	case <-time.After(time.Second):
+		t.Fatal("lease reentry timeout")
+	}
+}
+
+// 测试租约过期后被重入，卡住的原任务结束时调用 Unwatch 不影响新的执行
+func TestMonitorLeaseStaleUnwatch(t *testing.T) {
+	for _, sameNode := range []bool{false, true} {
+		store := NewMemoryStore()
+		group := "test_monitor_lease_stale"
+		started := make(chan string, 10)
+		finish := make(chan struct{})
+
+		newMonitor := func() Monitor {
+			m := NewMonitorWithStore(store,
+				WithHeartbeatTime(time.Millisecond*10),
+				WithHeartbeatTimeout(time.Minute), // 节点不会心跳超时
+				WithWatchTimeout(time.Minute),
+			)
+			m.Register("test_method", func(mctx MonitorContext) {
+				started <- m.(*monitorImpl).uid
+				<-finish
+			}, CallOpt{LeaseTTL: time.Millisecond * 30})
+			m.Start(group)
+			return m
+		}
+		m1 := newMonitor()
+		time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+		worker := m1
+		if !sameNode {
+			worker = newMonitor()
+		}
+
+		// 卡住的任务，不再续约
+		_, err := worker.Watch("test_method", "stuck", []byte("stuck"))
+		assert.NoError(t, err)
+		select {
+		case uid := <-started:
+			assert.Equal(t, uid, m1.(*monitorImpl).uid, sameNode)
+		case <-time.After(time.Second):
+			t.Fatal("lease reentry timeout")
+		}
+
+		// 原任务结束，Unwatch 被忽略
+		assert.NoError(t, worker.Unwatch("test_method", "stuck"))
+		keys, _ := m1.WatchList()
+		assert.Equal(t, keys, []string{group + "|test_method|stuck"}, sameNode)
+		body, err := store.GetContext(context.TODO(), group+"|test_method|stuck")
+		assert.Equal(t, []any{string(body), err}, []any{"stuck", nil}, sameNode)
+
+		// 新的执行结束后解除监控
+		close(finish)
+		assert.Eventually(t, func() bool {
+			keys, err := m1.WatchList()
+			return err == nil && len(keys) == 0
+		}, time.Second, time.Millisecond*10)
+		worker.Stop()
+		m1.Stop()
+	}
+}
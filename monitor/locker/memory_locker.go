@@ -0,0 +1,194 @@
+package locker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/FredyXue/go-utils"
+)
+
+// MemoryTable 进程内的锁存储
+// 同一个 MemoryTable 创建的 MemoryLocker 之间互斥
+type MemoryTable struct {
+	mu   sync.Mutex
+	keys map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	value    string
+	expireAt time.Time
+}
+
+func NewMemoryTable() *MemoryTable {
+	return &MemoryTable{
+		keys: make(map[string]memoryEntry),
+	}
+}
+
+// get 获取 now 时未过期的 key
+func (t *MemoryTable) get(key string, now time.Time) (entry memoryEntry, has bool) {
+	entry, has = t.keys[key]
+	if has && now.After(entry.expireAt) {
+		delete(t.keys, key)
+		has = false
+	}
+	return
+}
+
+// MemoryLocker 进程内的 Locker 实现，行为与 RedisLocker 保持一致
+// 适用于单进程工具与单元测试
+type MemoryLocker struct {
+	options
+	mu        sync.Mutex
+	table     *MemoryTable
+	cancelCtx context.Context
+	cancel    context.CancelFunc
+	initTime  time.Time // 首次加锁时间点
+	key       string
+	value     string
+}
+
+// NewMemoryLocker
+// 一个 MemoryLocker 对象一次只能管理一个 key
+func NewMemoryLocker(table *MemoryTable, opts ...Option) Locker {
+	return &MemoryLocker{
+		options: newOptions(opts...),
+		table:   table,
+	}
+}
+
+func (r *MemoryLocker) run(cancelCtx context.Context, initTime time.Time) {
+	ticker := r.clock.NewTicker(r.refreshTime)
+	defer ticker.Stop()
+
+lockerLabel:
+	for {
+		select {
+		case now := <-ticker.C():
+			// 超过最大时长，解锁
+			if initTime.Add(r.expiredTime).Before(now) {
+				r.Unlock()
+				break lockerLabel
+			}
+			r.refresh(cancelCtx)
+		case <-cancelCtx.Done():
+			break lockerLabel // ctx canceled 结束循环
+		}
+	}
+}
+
+func (r *MemoryLocker) Lock(key string) (success bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelCtx != nil && r.cancelCtx.Err() == nil {
+		return
+	}
+
+	now := r.clock.Now()
+	r.table.mu.Lock()
+	_, has := r.table.get(key, now)
+	if !has {
+		r.value = fmt.Sprintf("%d-%s", now.Unix(), utils.RandString(10)) // 确保 value 唯一
+		r.table.keys[key] = memoryEntry{value: r.value, expireAt: now.Add(r.lockTime)}
+	}
+	r.table.mu.Unlock()
+	r.observe(OpLock, !has, nil)
+	if has {
+		return
+	}
+
+	// 加锁成功
+	success = true
+	r.key = key
+	r.initTime = now
+	r.cancelCtx, r.cancel = context.WithCancel(context.TODO())
+
+	cancelCtx, initTime := r.cancelCtx, r.initTime
+	go utils.Protect(func() { r.run(cancelCtx, initTime) })
+	return
+}
+
+// Unlock 解锁当前 key
+func (r *MemoryLocker) Unlock() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancelCtx == nil || r.cancelCtx.Err() != nil {
+		return
+	}
+
+	r.table.mu.Lock()
+	entry, has := r.table.get(r.key, r.clock.Now())
+	owner := has && entry.value == r.value
+	if owner {
+		delete(r.table.keys, r.key)
+	}
+	r.table.mu.Unlock()
+	r.observe(OpUnlock, owner, nil)
+	r.release()
+}
+
+// UnlockForce 强制删除 key, 可以删除其他 key
+func (r *MemoryLocker) UnlockForce(key string) (owner bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.table.mu.Lock()
+	entry, has := r.table.get(key, r.clock.Now())
+	delete(r.table.keys, key)
+	r.table.mu.Unlock()
+
+	if has && r.key == key && entry.value == r.value {
+		owner = true
+		r.release()
+	}
+	return
+}
+
+func (r *MemoryLocker) refresh(cancelCtx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancelCtx.Err() != nil {
+		return
+	}
+
+	r.table.mu.Lock()
+	now := r.clock.Now()
+	entry, has := r.table.get(r.key, now)
+	if has && entry.value == r.value {
+		entry.expireAt = now.Add(r.lockTime)
+		r.table.keys[r.key] = entry
+	}
+	r.table.mu.Unlock()
+
+	r.observe(OpRefresh, has && entry.value == r.value, nil)
+	if !has || entry.value != r.value {
+		r.release() // 续约失败直接结束循环
+	}
+}
+
+// Check 判断 key 是否存在，owner 表示是否为当前对象的 key
+func (r *MemoryLocker) Check(key string) (exist bool, owner bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.key != key {
+		return
+	}
+
+	r.table.mu.Lock()
+	entry, has := r.table.get(key, r.clock.Now())
+	r.table.mu.Unlock()
+
+	exist = has
+	owner = has && entry.value == r.value
+	return
+}
+
+// release 清理本地加锁状态，调用方需持有 r.mu
+func (r *MemoryLocker) release() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.key = ""
+}
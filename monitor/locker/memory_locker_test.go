@@ -0,0 +1,96 @@
+package locker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FredyXue/go-utils/testdata"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryLocker(t *testing.T) {
+	table := NewMemoryTable()
+	key := "test_memory_locker"
+
+	// test for refresh
+	locker := NewMemoryLocker(table,
+		WithLockTime(time.Millisecond*30),   // 30ms
+		WithRefreshTime(time.Millisecond*5), // 5ms
+		WithExpiredTime(time.Minute*30),
+	)
+	locker2 := NewMemoryLocker(table, WithLockTime(time.Millisecond*30))
+
+	success, err := locker.Lock(key)
+	assert.NoError(t, err)
+	assert.Equal(t, success, true)
+	success, _ = locker2.Lock(key) // 同一个 table 互斥
+	assert.Equal(t, success, false)
+
+	time.Sleep(time.Millisecond * 100) // 休眠 100ms, 验证 key 还存活
+	exist, owner, _ := locker.Check(key)
+	assert.Equal(t, []any{exist, owner}, []any{true, true})
+
+	locker.Unlock()
+	exist, owner, _ = locker.Check(key)
+	assert.Equal(t, []any{exist, owner}, []any{false, false})
+	success, _ = locker2.Lock(key) // 解锁后可以再次加锁
+	assert.Equal(t, success, true)
+	locker2.Unlock()
+
+	// test for expired
+	locker = NewMemoryLocker(table,
+		WithLockTime(time.Millisecond*30),    // 30ms
+		WithRefreshTime(time.Millisecond*5),  // 5ms
+		WithExpiredTime(time.Millisecond*90), // 90ms
+	)
+	locker.Lock(key)
+	time.Sleep(time.Millisecond * 60)
+	exist, owner, _ = locker.Check(key)
+	assert.Equal(t, []any{exist, owner}, []any{true, true})
+
+	time.Sleep(time.Millisecond * 60) // 超时
+	exist, owner, _ = locker.Check(key)
+	assert.Equal(t, []any{exist, owner}, []any{false, false})
+
+	// test for unlock force
+	locker.Lock(key)
+	owner, err = locker2.UnlockForce(key) // 删除其他对象的 key
+	assert.NoError(t, err)
+	assert.Equal(t, owner, false)
+	exist, owner, _ = locker.Check(key)
+	assert.Equal(t, []any{exist, owner}, []any{false, false})
+}
+
+// 测试使用 FakeClock 控制续约周期与最大时长，无需真实等待
+func TestMemoryLockerClock(t *testing.T) {
+	clock := testdata.NewFakeClock(time.Now())
+	table := NewMemoryTable()
+	key := "test_memory_locker_clock"
+
+	locker := NewMemoryLocker(table,
+		WithClock(clock),
+		WithLockTime(time.Minute*3),
+		WithRefreshTime(time.Minute),
+		WithExpiredTime(time.Minute*30),
+	)
+	locker2 := NewMemoryLocker(table, WithClock(clock))
+
+	success, _ := locker.Lock(key)
+	assert.Equal(t, success, true)
+	assert.Eventually(t, func() bool { return clock.Tickers() == 1 }, time.Second, time.Millisecond) // 等待续约循环启动
+
+	clock.Step(time.Minute*10, time.Minute) // 超过加锁时长，持续续约
+	exist, owner, _ := locker.Check(key)
+	assert.Equal(t, []any{exist, owner}, []any{true, true})
+	success, _ = locker2.Lock(key)
+	assert.Equal(t, success, false)
+
+	clock.Step(time.Minute*21, time.Minute) // 超过最大时长，解锁
+	assert.Eventually(t, func() bool {
+		exist, _, _ := locker.Check(key)
+		return !exist
+	}, time.Second, time.Millisecond)
+	success, _ = locker2.Lock(key)
+	assert.Equal(t, success, true)
+	locker2.Unlock()
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
-	"sync/atomic"
+	"sync"
 	"time"
 
 	"github.com/FredyXue/go-utils"
@@ -21,62 +21,121 @@ type Locker interface {
 }
 
 // Option
-type Option func(*RedisLocker)
+type Option func(*options)
 
 func WithLockTime(lockTime time.Duration) Option {
-	return func(r *RedisLocker) {
+	return func(r *options) {
 		r.lockTime = lockTime
 	}
 }
 
 func WithRefreshTime(refreshTime time.Duration) Option {
-	return func(r *RedisLocker) {
+	return func(r *options) {
 		r.refreshTime = refreshTime
 	}
 }
 
 func WithExpiredTime(expiredTime time.Duration) Option {
-	return func(r *RedisLocker) {
+	return func(r *options) {
 		r.expiredTime = expiredTime
 	}
 }
 
 func WithContext(ctx context.Context) Option {
-	return func(r *RedisLocker) {
+	return func(r *options) {
 		r.ctx = ctx
 	}
 }
 
-// RedisLocker .
-type RedisLocker struct {
+// WithClock 时钟，默认系统时钟。控制续约周期与最大时长，redis 中 key 的过期仍由 redis 计算
+func WithClock(clock utils.Clock) Option {
+	return func(r *options) {
+		r.clock = clock
+	}
+}
+
+// WithObserver 观察加锁、续约、解锁的结果，例如用于统计指标
+func WithObserver(observer Observer) Option {
+	return func(r *options) {
+		r.observer = observer
+	}
+}
+
+// Observer 在持有 Locker 内部锁时同步调用，不应阻塞
+// op: OpLock, OpRefresh, OpUnlock;  outcome: OutcomeSuccess, OutcomeFailed, OutcomeError
+type Observer func(op string, outcome string)
+
+const (
+	OpLock    = "lock"
+	OpRefresh = "refresh"
+	OpUnlock  = "unlock"
+
+	OutcomeSuccess = "success" // 成功
+	OutcomeFailed  = "failed"  // 锁已被占用，或已不属于当前对象
+	OutcomeError   = "error"   // 存储报错
+)
+
+// options 各类 Locker 的公共配置
+type options struct {
 	ctx         context.Context // 业务 ctx
-	cancelCtx   context.Context
-	cancel      context.CancelFunc
-	cli         *redis.Client
-	lockTime    time.Duration // 加锁时长，每次续约的时长
-	refreshTime time.Duration // 锁续约的周期
-	expiredTime time.Duration // 最大时长
-	initTime    time.Time     // 首次加锁时间点
-	locked      int32         // 0 未加锁 1 加锁
-	key         string
-	value       string
-	refreshCmd  string // expire, pexpire
-	refreshDur  int64  // s/ms
+	lockTime    time.Duration   // 加锁时长，每次续约的时长
+	refreshTime time.Duration   // 锁续约的周期
+	expiredTime time.Duration   // 最大时长
+	observer    Observer        // 结果观察者
+	clock       utils.Clock     // 时钟
 }
 
-// NewRedisLocker
-// 一个 RedisLocker 对象一次只能管理一个 key
-// 当 key 解锁后，可以再次管理一个新的 key
-func NewRedisLocker(redisCli *redis.Client, opts ...Option) Locker {
-	r := &RedisLocker{
-		cli:         redisCli,
+func newOptions(opts ...Option) options {
+	o := options{
 		lockTime:    time.Minute * 3,  // 默认加锁 3 分钟
 		refreshTime: time.Minute,      // 默认 1 分钟续约
 		expiredTime: time.Minute * 30, // 默认最大时长 30 分钟
 		ctx:         context.TODO(),
+		clock:       utils.RealClock,
 	}
-	for _, o := range opts {
-		o(r)
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o *options) observe(op string, success bool, err error) {
+	if o.observer == nil {
+		return
+	}
+	switch {
+	case err != nil:
+		o.observer(op, OutcomeError)
+	case success:
+		o.observer(op, OutcomeSuccess)
+	default:
+		o.observer(op, OutcomeFailed)
+	}
+}
+
+// RedisLocker .
+// mu 保护加锁状态，Lock/Unlock 与续约循环可以在不同 goroutine 中并发调用
+type RedisLocker struct {
+	options
+	mu         sync.Mutex
+	cancelCtx  context.Context
+	cancel     context.CancelFunc
+	cli        redis.UniversalClient
+	initTime   time.Time // 首次加锁时间点
+	key        string
+	value      string
+	refreshCmd string // expire, pexpire
+	refreshDur int64  // s/ms
+}
+
+// NewRedisLocker
+// 一个 RedisLocker 对象一次只能管理一个 key
+// 当 key 解锁后，可以再次管理一个新的 key
+// 支持 *redis.Client、*redis.ClusterClient 等 redis.UniversalClient，每个脚本只操作一个 key
+func NewRedisLocker(redisCli redis.UniversalClient, opts ...Option) Locker {
+	r := &RedisLocker{
+		options: newOptions(opts...),
+		cli:     redisCli,
 	}
 
 	// 计算刷新时间，每次续约 lockTime
@@ -90,21 +149,21 @@ func NewRedisLocker(redisCli *redis.Client, opts ...Option) Locker {
 	return r
 }
 
-func (r *RedisLocker) run(cancelCtx context.Context) {
-	ticker := time.NewTicker(r.refreshTime)
+func (r *RedisLocker) run(cancelCtx context.Context, initTime time.Time) {
+	ticker := r.clock.NewTicker(r.refreshTime)
 	defer ticker.Stop()
 
 	// lock
 lockerLabel:
 	for {
 		select {
-		case now := <-ticker.C:
+		case now := <-ticker.C():
 			// 超过最大时长，解锁
-			if r.initTime.Add(r.expiredTime).Before(now) {
+			if initTime.Add(r.expiredTime).Before(now) {
 				r.Unlock()
 				break
 			}
-			r.refresh() // refresh
+			r.refresh(cancelCtx) // refresh
 		case <-cancelCtx.Done():
 			break lockerLabel // ctx canceled 结束循环
 		}
@@ -112,40 +171,40 @@ lockerLabel:
 }
 
 func (r *RedisLocker) Lock(key string) (success bool, err error) {
+	// 持有 mu 加锁，针对并发 lock 且 key 不同的场景
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.cancelCtx != nil && r.cancelCtx.Err() == nil {
 		return
 	}
 
-	// CAS 加锁，针对连续两次 lock 且 key 不同的场景
-	if !atomic.CompareAndSwapInt32(&r.locked, 0, 1) {
-		return
-	}
-
-	value := fmt.Sprintf("%d-%s", time.Now().Unix(), utils.RandString(10)) // 确保 value 唯一
+	value := fmt.Sprintf("%d-%s", r.clock.Now().Unix(), utils.RandString(10)) // 确保 value 唯一
 	success, err = r.cli.SetNX(r.ctx, key, value, r.lockTime).Result()
+	r.observe(OpLock, success, err)
 	if err != nil {
 		err = errors.Wrap(err, "RedisLocker Lock Error")
-		r.locked = 0
 		return
 	}
 	if !success {
-		r.locked = 0 // 还原标记
 		return
 	}
 
 	// 加锁成功
 	r.value = value
 	r.key = key
-	r.initTime = time.Now()
+	r.initTime = r.clock.Now()
 
 	r.cancelCtx, r.cancel = context.WithCancel(context.TODO()) // 建立 cancel ctx
 
-	go utils.Protect(func() { r.run(r.cancelCtx) }) // 开启续约，cancelCtx 作为入参，避免因为并发被替换
+	cancelCtx, initTime := r.cancelCtx, r.initTime
+	go utils.Protect(func() { r.run(cancelCtx, initTime) }) // 开启续约，cancelCtx 作为入参，避免因为并发被替换
 	return
 }
 
 // Unlock 解锁当前 key
 func (r *RedisLocker) Unlock() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.cancelCtx == nil || r.cancelCtx.Err() != nil {
 		return
 	}
@@ -157,18 +216,21 @@ func (r *RedisLocker) Unlock() {
 		return 0
 	end
 	`
-	err := r.cli.Eval(r.ctx, lua, []string{r.key}, r.value).Err()
+	rlt, err := r.cli.Eval(r.ctx, lua, []string{r.key}, r.value).Int()
+	r.observe(OpUnlock, rlt == 1, err)
 	if err != nil {
 		log.Println("RedisLocker UnLock Error", err)
 	}
-	r.cancel()   // 无论 redis 解锁是否成功都直接结束循环。若解锁失败，则等到锁自动过期
-	r.locked = 0 // 标记解锁
+	r.cancel() // 无论 redis 解锁是否成功都直接结束循环。若解锁失败，则等到锁自动过期
 	r.key = ""
 }
 
 // UnlockForce  强制删除 key, 可以删除其他 key
 // 提供一个入口，为了可以使用相同的存储方式去删除 key
 func (r *RedisLocker) UnlockForce(key string) (owner bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	// 与当前 key 不相同直接删除
 	if r.key != key {
 		err = r.cli.Del(r.ctx, key).Err()
@@ -194,15 +256,16 @@ func (r *RedisLocker) UnlockForce(key string) (owner bool, err error) {
 	// value 匹配，为当前 key。解锁对象
 	if rlt == 1 {
 		owner = true
-		r.cancel()   // 结束循环
-		r.locked = 0 // 标记解锁
+		r.cancel() // 结束循环
 		r.key = ""
 	}
 	return
 }
 
-func (r *RedisLocker) refresh() {
-	if r.cancelCtx == nil || r.cancelCtx.Err() != nil {
+func (r *RedisLocker) refresh(cancelCtx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cancelCtx.Err() != nil {
 		return
 	}
 
@@ -215,13 +278,13 @@ func (r *RedisLocker) refresh() {
 	end
 	`, r.refreshCmd)
 	rlt, err := r.cli.Eval(r.ctx, lua, []string{r.key}, r.value, r.refreshDur).Int()
+	r.observe(OpRefresh, rlt == 1, err)
 	if err != nil {
 		log.Println("RedisLocker refresh Error", err) // 报错继续循环
 		return
 	}
 	if rlt == 0 {
-		r.cancel()   // 续约失败直接结束循环
-		r.locked = 0 // 标记解锁
+		r.cancel() // 续约失败直接结束循环
 		r.key = ""
 	}
 }
@@ -230,6 +293,8 @@ func (r *RedisLocker) refresh() {
 // 提供一个入口，为了可以使用相同的存储方式去查询
 // owner 表示是否为当前对象的 key
 func (r *RedisLocker) Check(key string) (exist bool, owner bool, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if r.key != key {
 		return
 	}
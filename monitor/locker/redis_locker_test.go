@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"log"
+	"sync"
 	"testing"
 	"time"
 
@@ -90,3 +91,34 @@ func TestRedisLocker(t *testing.T) {
 	assert.Equal(t, errors.Is(err, redis.Nil), true)
 	assert.Equal(t, []any{exist, owner, val}, []any{false, false, ""})
 }
+
+func TestRedisLockerObserver(t *testing.T) {
+	redisClient := testdata.NewTestRedis()
+	key := "test_redis_locker_observer"
+	var mu sync.Mutex
+	outcomes := make(map[string]int)
+	observer := func(op string, outcome string) {
+		mu.Lock()
+		defer mu.Unlock()
+		outcomes[op+":"+outcome]++
+	}
+
+	locker := NewRedisLocker(redisClient,
+		WithLockTime(time.Millisecond*30),
+		WithRefreshTime(time.Millisecond*5),
+		WithObserver(observer),
+	)
+	locker2 := NewRedisLocker(redisClient, WithObserver(observer))
+
+	success, _ := locker.Lock(key)
+	assert.Equal(t, success, true)
+	success, _ = locker2.Lock(key)
+	assert.Equal(t, success, false)
+	time.Sleep(time.Millisecond * 20)
+	locker.Unlock()
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []any{outcomes["lock:success"], outcomes["lock:failed"], outcomes["unlock:success"]}, []any{1, 1, 1})
+	assert.Equal(t, outcomes["refresh:success"] > 0, true)
+}
@@ -0,0 +1,512 @@
+package monitor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/FredyXue/go-utils"
+	"github.com/FredyXue/go-utils/monitor/locker"
+)
+
+// memoryStore 进程内存储
+// 适用于单进程工具与单元测试，同一个 memoryStore 上的多个 Monitor 可以互相重入任务
+type memoryStore struct {
+	mu        sync.Mutex
+	nodes     map[string]map[string]int64          // group -> uid -> timestamp
+	watches   map[string]map[string]string         // group -> key -> uid
+	times     map[string]map[string]int64          // group -> key -> timestamp
+	attempts  map[string]map[string]int64          // group -> key -> count
+	letters   map[string]map[string][]byte         // group -> key -> 死信
+	crons     map[string]map[string]int64          // group -> name -> ms
+	schedules map[string]map[string]memorySchedule // group -> key -> 延时任务
+	assigns   map[string]map[string]string         // group:uid -> key -> from
+	leases    map[string]map[string]time.Time      // group -> key -> 过期时间
+	retryAts  map[string]map[string]time.Time      // group -> key -> 下一次重入时间
+	contexts  map[string]memoryContext             // key -> context
+	steps     map[string]memorySteps               // key -> steps，与上下文的时长一致
+	masters   map[string]memoryContext             // group -> master uid
+	cancels   map[string][]*memorySubscriber       // group -> 取消任务的订阅者
+	table     *locker.MemoryTable
+	clock     utils.Clock // 计算上下文与 master 的过期时间
+}
+
+// MemoryStoreOpt NewMemoryStore 的配置
+type MemoryStoreOpt func(*memoryStore)
+
+// WithMemoryClock 时钟，默认系统时钟。单元测试中与 monitor 使用同一个时钟，上下文随模拟时间过期
+func WithMemoryClock(clock utils.Clock) MemoryStoreOpt {
+	return func(s *memoryStore) {
+		s.clock = clock
+	}
+}
+
+type memoryContext struct {
+	body     []byte
+	expireAt time.Time // 零值表示永不过期
+}
+
+type memorySchedule struct {
+	runAt time.Time
+	body  []byte
+}
+
+// memorySubscriber 订阅者，ctx 结束时移除
+type memorySubscriber struct {
+	ctx context.Context
+	fn  func(key string)
+}
+
+type memorySteps struct {
+	steps    map[string][]byte // step -> body
+	expireAt time.Time         // 零值表示永不过期
+}
+
+// expireAt expiration <= 0 表示永不过期
+func (s *memoryStore) expireAt(expiration time.Duration) (t time.Time) {
+	if expiration > 0 {
+		t = s.clock.Now().Add(expiration)
+	}
+	return
+}
+
+func NewMemoryStore(opts ...MemoryStoreOpt) Store {
+	s := &memoryStore{
+		nodes:     make(map[string]map[string]int64),
+		watches:   make(map[string]map[string]string),
+		times:     make(map[string]map[string]int64),
+		attempts:  make(map[string]map[string]int64),
+		letters:   make(map[string]map[string][]byte),
+		crons:     make(map[string]map[string]int64),
+		schedules: make(map[string]map[string]memorySchedule),
+		assigns:   make(map[string]map[string]string),
+		leases:    make(map[string]map[string]time.Time),
+		retryAts:  make(map[string]map[string]time.Time),
+		contexts:  make(map[string]memoryContext),
+		steps:     make(map[string]memorySteps),
+		masters:   make(map[string]memoryContext),
+		cancels:   make(map[string][]*memorySubscriber),
+		table:     locker.NewMemoryTable(),
+		clock:     utils.RealClock,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *memoryStore) Heartbeat(ctx context.Context, group string, uid string, timestamp int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nodes[group] == nil {
+		s.nodes[group] = make(map[string]int64)
+	}
+	s.nodes[group][uid] = timestamp
+	return nil
+}
+
+func (s *memoryStore) NodeList(ctx context.Context, group string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	nodes := make(map[string]int64, len(s.nodes[group]))
+	for uid, timestamp := range s.nodes[group] {
+		nodes[uid] = timestamp
+	}
+	return nodes, nil
+}
+
+func (s *memoryStore) RemoveNode(ctx context.Context, group string, uid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.nodes[group], uid)
+	delete(s.assigns, group+":"+uid)
+	return nil
+}
+
+func (s *memoryStore) AddWatch(ctx context.Context, group string, key string, uid string, timestamp int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watches[group] == nil {
+		s.watches[group] = make(map[string]string)
+	}
+	s.watches[group][key] = uid
+	if s.times[group] == nil {
+		s.times[group] = make(map[string]int64)
+	}
+	if _, has := s.times[group][key]; !has {
+		s.times[group][key] = timestamp // 重入时不更新
+	}
+	delete(s.retryAts[group], key)
+	return nil
+}
+
+func (s *memoryStore) RemoveWatch(ctx context.Context, group string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeWatchLocked(group, key)
+	return nil
+}
+
+// removeWatchLocked 调用方持有 s.mu
+func (s *memoryStore) removeWatchLocked(group string, key string) {
+	delete(s.watches[group], key)
+	delete(s.times[group], key)
+	delete(s.attempts[group], key)
+	delete(s.leases[group], key)
+	delete(s.retryAts[group], key)
+}
+
+func (s *memoryStore) RemoveWatchIf(ctx context.Context, group string, key string, uid string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if owner, has := s.watches[group][key]; !has || owner != uid {
+		return false, nil
+	}
+	s.removeWatchLocked(group, key)
+	return true, nil
+}
+
+func (s *memoryStore) WatchList(ctx context.Context, group string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps := make(map[string]string, len(s.watches[group]))
+	for key, uid := range s.watches[group] {
+		maps[key] = uid
+	}
+	return maps, nil
+}
+
+func (s *memoryStore) WatchTimes(ctx context.Context, group string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	times := make(map[string]int64, len(s.times[group]))
+	for key, timestamp := range s.times[group] {
+		times[key] = timestamp
+	}
+	return times, nil
+}
+
+func (s *memoryStore) IncrAttempts(ctx context.Context, group string, key string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.attempts[group] == nil {
+		s.attempts[group] = make(map[string]int64)
+	}
+	s.attempts[group][key]++
+	return s.attempts[group][key], nil
+}
+
+func (s *memoryStore) ClaimWatch(ctx context.Context, group string, key string, from string, to string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	owner, has := s.watches[group][key]
+	if !has || (owner != from && owner != to) {
+		return false, nil
+	}
+	s.watches[group][key] = to
+	delete(s.retryAts[group], key)
+	return true, nil
+}
+
+func (s *memoryStore) ReleaseWatch(ctx context.Context, group string, key string, uid string, retryAt time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if owner, has := s.watches[group][key]; !has || owner != uid {
+		return false, nil
+	}
+	s.watches[group][key] = orphanUID
+	if s.retryAts[group] == nil {
+		s.retryAts[group] = make(map[string]time.Time)
+	}
+	s.retryAts[group][key] = retryAt
+	return true, nil
+}
+
+func (s *memoryStore) DelayedWatches(ctx context.Context, group string, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0)
+	for key, retryAt := range s.retryAts[group] {
+		if retryAt.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryStore) SetLease(ctx context.Context, group string, key string, expireAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[group] == nil {
+		s.leases[group] = make(map[string]time.Time)
+	}
+	s.leases[group][key] = expireAt
+	return nil
+}
+
+func (s *memoryStore) ExpiredLeases(ctx context.Context, group string, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := make([]string, 0)
+	for key, expireAt := range s.leases[group] {
+		if !expireAt.After(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *memoryStore) Assign(ctx context.Context, group string, key string, from string, to string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if owner, has := s.watches[group][key]; !has || owner != from {
+		return false, nil
+	}
+	s.watches[group][key] = to
+	if s.assigns[group+":"+to] == nil {
+		s.assigns[group+":"+to] = make(map[string]string)
+	}
+	s.assigns[group+":"+to][key] = from
+	return true, nil
+}
+
+func (s *memoryStore) Assignments(ctx context.Context, group string, uid string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps := s.assigns[group+":"+uid]
+	delete(s.assigns, group+":"+uid)
+	if maps == nil {
+		maps = make(map[string]string)
+	}
+	return maps, nil
+}
+
+func (s *memoryStore) AddSchedule(ctx context.Context, group string, key string, runAt time.Time, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schedules[group] == nil {
+		s.schedules[group] = make(map[string]memorySchedule)
+	}
+	s.schedules[group][key] = memorySchedule{runAt: runAt, body: body}
+	return nil
+}
+
+func (s *memoryStore) DueSchedules(ctx context.Context, group string, now time.Time) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps := make(map[string][]byte)
+	for key, schedule := range s.schedules[group] {
+		if !schedule.runAt.After(now) {
+			maps[key] = schedule.body
+		}
+	}
+	return maps, nil
+}
+
+func (s *memoryStore) RemoveSchedule(ctx context.Context, group string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.schedules[group], key)
+	return nil
+}
+
+func (s *memoryStore) CronTimes(ctx context.Context, group string) (map[string]int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	times := make(map[string]int64, len(s.crons[group]))
+	for name, ms := range s.crons[group] {
+		times[name] = ms
+	}
+	return times, nil
+}
+
+func (s *memoryStore) SetCronTime(ctx context.Context, group string, name string, runAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.crons[group] == nil {
+		s.crons[group] = make(map[string]int64)
+	}
+	if last, has := s.crons[group][name]; !has || last < runAt.UnixMilli() {
+		s.crons[group][name] = runAt.UnixMilli()
+	}
+	return nil
+}
+
+// PublishCancel 在调用方的 goroutine 中同步通知订阅者
+func (s *memoryStore) PublishCancel(ctx context.Context, group string, key string) error {
+	s.mu.Lock()
+	subs := append([]*memorySubscriber(nil), s.cancels[group]...)
+	s.mu.Unlock()
+	for _, sub := range subs {
+		if sub.ctx.Err() == nil {
+			sub.fn(key)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) SubscribeCancel(ctx context.Context, group string, fn func(key string)) (<-chan struct{}, error) {
+	sub := &memorySubscriber{ctx: ctx, fn: fn}
+	s.mu.Lock()
+	s.cancels[group] = append(s.cancels[group], sub)
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go utils.Protect(func() {
+		defer close(done)
+		<-ctx.Done()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		subs := s.cancels[group][:0]
+		for _, v := range s.cancels[group] {
+			if v != sub {
+				subs = append(subs, v)
+			}
+		}
+		s.cancels[group] = subs
+	})
+	return done, nil
+}
+
+func (s *memoryStore) AddDeadLetter(ctx context.Context, group string, key string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.watches[group], key)
+	delete(s.times[group], key)
+	delete(s.attempts[group], key)
+	delete(s.leases[group], key)
+	delete(s.retryAts[group], key)
+	if s.letters[group] == nil {
+		s.letters[group] = make(map[string][]byte)
+	}
+	s.letters[group][key] = body
+	return nil
+}
+
+func (s *memoryStore) DeadLetters(ctx context.Context, group string) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps := make(map[string][]byte, len(s.letters[group]))
+	for key, body := range s.letters[group] {
+		maps[key] = body
+	}
+	return maps, nil
+}
+
+func (s *memoryStore) RemoveDeadLetter(ctx context.Context, group string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.letters[group], key)
+	return nil
+}
+
+// getUnexpired 获取未过期的上下文，过期时删除，调用方需持有 s.mu
+func (s *memoryStore) getUnexpired(values map[string]memoryContext, key string) (c memoryContext, has bool) {
+	c, has = values[key]
+	if has && !c.expireAt.IsZero() && s.clock.Now().After(c.expireAt) {
+		delete(values, key)
+		has = false
+	}
+	return
+}
+
+func (s *memoryStore) GetContext(ctx context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, has := s.getUnexpired(s.contexts, key)
+	if !has {
+		return nil, ErrNil
+	}
+	return append([]byte{}, c.body...), nil
+}
+
+func (s *memoryStore) SetContext(ctx context.Context, key string, body []byte, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contexts[key] = memoryContext{body: append([]byte{}, body...), expireAt: s.expireAt(expiration)}
+	if st, has := s.getSteps(key); has {
+		st.expireAt = s.expireAt(expiration)
+		s.steps[key] = st
+	}
+	return nil
+}
+
+func (s *memoryStore) ContextTTL(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, has := s.getUnexpired(s.contexts, key)
+	if !has {
+		return -2, nil
+	}
+	if c.expireAt.IsZero() {
+		return -1, nil
+	}
+	return c.expireAt.Sub(s.clock.Now()), nil
+}
+
+func (s *memoryStore) DelContext(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.contexts, key)
+	delete(s.steps, key)
+	return nil
+}
+
+func (s *memoryStore) SetStep(ctx context.Context, key string, step string, body []byte, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, has := s.getSteps(key)
+	if !has {
+		st.steps = make(map[string][]byte)
+	}
+	st.steps[step] = append([]byte{}, body...)
+	st.expireAt = s.expireAt(expiration)
+	s.steps[key] = st
+	// 刷新上下文时长
+	if c, has := s.getUnexpired(s.contexts, key); has {
+		c.expireAt = s.expireAt(expiration)
+		s.contexts[key] = c
+	}
+	return nil
+}
+
+func (s *memoryStore) GetSteps(ctx context.Context, key string) (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, _ := s.getSteps(key)
+	maps := make(map[string][]byte, len(st.steps))
+	for step, body := range st.steps {
+		maps[step] = append([]byte{}, body...)
+	}
+	return maps, nil
+}
+
+// getSteps 获取未过期的步骤，过期时删除，调用方需持有 s.mu
+func (s *memoryStore) getSteps(key string) (st memorySteps, has bool) {
+	st, has = s.steps[key]
+	if has && !st.expireAt.IsZero() && s.clock.Now().After(st.expireAt) {
+		delete(s.steps, key)
+		st, has = memorySteps{}, false
+	}
+	return
+}
+
+func (s *memoryStore) SetMaster(ctx context.Context, group string, uid string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.masters[group] = memoryContext{body: []byte(uid), expireAt: s.expireAt(expiration)}
+	return nil
+}
+
+func (s *memoryStore) GetMaster(ctx context.Context, group string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, has := s.getUnexpired(s.masters, group)
+	if !has {
+		return "", ErrNil
+	}
+	return string(c.body), nil
+}
+
+func (s *memoryStore) NewLocker(opts ...locker.Option) locker.Locker {
+	return locker.NewMemoryLocker(s.table, opts...)
+}
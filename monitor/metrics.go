@@ -0,0 +1,272 @@
+package monitor
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FredyXue/go-utils/monitor/locker"
+)
+
+// metricsBuckets 重入耗时直方图的分桶，单位秒
+var metricsBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+// Metrics monitor 与 locker 的指标收集器
+// 以 Prometheus 文本格式输出，不依赖 prometheus client。多个 Monitor 可以共用一个 Metrics
+type Metrics struct {
+	mu          sync.Mutex
+	nodes       map[string]int                     // group -> 存活节点数，由 master 上报
+	watches     map[string]map[string]int          // group -> method -> 任务数，由 master 上报
+	reentries   map[string]map[string]*reentryHist // group -> method -> 重入统计
+	longRunning map[string]map[string]int64        // group -> method -> 长耗时预警次数
+	elections   map[string]int64                   // group -> 选举为 master 的次数
+	locker      map[string]map[string]int64        // op -> outcome -> 次数
+}
+
+type reentryHist struct {
+	ReentryStat
+	buckets []int64 // 与 metricsBuckets 对应，非累加
+}
+
+// MetricsSnapshot 指标快照
+type MetricsSnapshot struct {
+	Nodes       map[string]int                    // group -> 存活节点数
+	Watches     map[string]map[string]int         // group -> method -> 任务数
+	Reentries   map[string]map[string]ReentryStat // group -> method -> 重入统计
+	LongRunning map[string]map[string]int64       // group -> method -> 长耗时预警次数
+	Elections   map[string]int64                  // group -> 选举为 master 的次数
+	Locker      map[string]map[string]int64       // op -> outcome -> 次数
+}
+
+// ReentryStat 重入统计
+type ReentryStat struct {
+	Count    int64         // 重入结束的次数
+	Failed   int64         // 返回 error 的次数
+	Duration time.Duration // 累计耗时
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		nodes:       make(map[string]int),
+		watches:     make(map[string]map[string]int),
+		reentries:   make(map[string]map[string]*reentryHist),
+		longRunning: make(map[string]map[string]int64),
+		elections:   make(map[string]int64),
+		locker:      make(map[string]map[string]int64),
+	}
+}
+
+// WithMetrics 收集 monitor 与心跳 Locker 的指标
+func WithMetrics(metrics *Metrics) MOpt {
+	return func(r *monitorImpl) {
+		r.metrics = metrics
+		r.eventFuncs = append(r.eventFuncs, metrics.observeEvent)
+	}
+}
+
+// LockerObserver 用于独立使用的 Locker
+// locker.NewRedisLocker(cli, locker.WithObserver(metrics.LockerObserver()))
+func (s *Metrics) LockerObserver() locker.Observer {
+	return s.observeLocker
+}
+
+func (s *Metrics) observeLocker(op string, outcome string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.locker[op] == nil {
+		s.locker[op] = make(map[string]int64)
+	}
+	s.locker[op][outcome]++
+}
+
+func (s *Metrics) observeEvent(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e.Type {
+	case EventMasterElected:
+		s.elections[e.Group]++
+	case EventMasterLost:
+		// 只有 master 上报节点与任务数，失去 master 后清理
+		delete(s.nodes, e.Group)
+		delete(s.watches, e.Group)
+	case EventLongRunning:
+		if s.longRunning[e.Group] == nil {
+			s.longRunning[e.Group] = make(map[string]int64)
+		}
+		s.longRunning[e.Group][e.Method]++
+	case EventReentryFinished:
+		if s.reentries[e.Group] == nil {
+			s.reentries[e.Group] = make(map[string]*reentryHist)
+		}
+		h := s.reentries[e.Group][e.Method]
+		if h == nil {
+			h = &reentryHist{buckets: make([]int64, len(metricsBuckets))}
+			s.reentries[e.Group][e.Method] = h
+		}
+		h.Count++
+		h.Duration += e.Cost
+		if e.Err != nil {
+			h.Failed++
+		}
+		if i := sort.SearchFloat64s(metricsBuckets, e.Cost.Seconds()); i < len(metricsBuckets) {
+			h.buckets[i]++
+		}
+	}
+}
+
+// setNodes master 上报存活节点数
+func (s *Metrics) setNodes(group string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[group] = count
+}
+
+// setWatches master 上报各 method 的任务数
+func (s *Metrics) setWatches(group string, counts map[string]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.watches[group] = counts
+}
+
+// Snapshot 获取当前指标的快照
+func (s *Metrics) Snapshot() MetricsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := MetricsSnapshot{
+		Nodes:       make(map[string]int, len(s.nodes)),
+		Watches:     make(map[string]map[string]int, len(s.watches)),
+		Reentries:   make(map[string]map[string]ReentryStat, len(s.reentries)),
+		LongRunning: make(map[string]map[string]int64, len(s.longRunning)),
+		Elections:   make(map[string]int64, len(s.elections)),
+		Locker:      make(map[string]map[string]int64, len(s.locker)),
+	}
+	for group, count := range s.nodes {
+		snap.Nodes[group] = count
+	}
+	for group, methods := range s.watches {
+		snap.Watches[group] = make(map[string]int, len(methods))
+		for method, count := range methods {
+			snap.Watches[group][method] = count
+		}
+	}
+	for group, methods := range s.reentries {
+		snap.Reentries[group] = make(map[string]ReentryStat, len(methods))
+		for method, h := range methods {
+			snap.Reentries[group][method] = h.ReentryStat
+		}
+	}
+	for group, methods := range s.longRunning {
+		snap.LongRunning[group] = make(map[string]int64, len(methods))
+		for method, count := range methods {
+			snap.LongRunning[group][method] = count
+		}
+	}
+	for group, count := range s.elections {
+		snap.Elections[group] = count
+	}
+	for op, outcomes := range s.locker {
+		snap.Locker[op] = make(map[string]int64, len(outcomes))
+		for outcome, count := range outcomes {
+			snap.Locker[op][outcome] = count
+		}
+	}
+	return snap
+}
+
+// ServeHTTP 以 Prometheus 文本格式输出指标
+// http.Handle("/metrics", metrics)
+func (s *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	s.WriteTo(w)
+}
+
+// WriteTo 以 Prometheus 文本格式输出指标
+func (s *Metrics) WriteTo(w io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	header := func(name string, typ string, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+	}
+
+	header("monitor_nodes", "gauge", "Live nodes per group, reported by master.")
+	for _, group := range sortedKeys(s.nodes) {
+		fmt.Fprintf(&b, "monitor_nodes{group=%s} %d\n", quoteLabel(group), s.nodes[group])
+	}
+
+	header("monitor_watches", "gauge", "Watches per method, reported by master.")
+	for _, group := range sortedKeys(s.watches) {
+		for _, method := range sortedKeys(s.watches[group]) {
+			fmt.Fprintf(&b, "monitor_watches{group=%s,method=%s} %d\n", quoteLabel(group), quoteLabel(method), s.watches[group][method])
+		}
+	}
+
+	header("monitor_reentry_total", "counter", "Finished reentries per method and result.")
+	for _, group := range sortedKeys(s.reentries) {
+		for _, method := range sortedKeys(s.reentries[group]) {
+			h := s.reentries[group][method]
+			labels := fmt.Sprintf("group=%s,method=%s", quoteLabel(group), quoteLabel(method))
+			fmt.Fprintf(&b, "monitor_reentry_total{%s,result=\"success\"} %d\n", labels, h.Count-h.Failed)
+			fmt.Fprintf(&b, "monitor_reentry_total{%s,result=\"error\"} %d\n", labels, h.Failed)
+		}
+	}
+
+	header("monitor_reentry_duration_seconds", "histogram", "Reentry duration per method.")
+	for _, group := range sortedKeys(s.reentries) {
+		for _, method := range sortedKeys(s.reentries[group]) {
+			h := s.reentries[group][method]
+			labels := fmt.Sprintf("group=%s,method=%s", quoteLabel(group), quoteLabel(method))
+			var cumulative int64
+			for i, le := range metricsBuckets {
+				cumulative += h.buckets[i]
+				fmt.Fprintf(&b, "monitor_reentry_duration_seconds_bucket{%s,le=\"%g\"} %d\n", labels, le, cumulative)
+			}
+			fmt.Fprintf(&b, "monitor_reentry_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.Count)
+			fmt.Fprintf(&b, "monitor_reentry_duration_seconds_sum{%s} %g\n", labels, h.Duration.Seconds())
+			fmt.Fprintf(&b, "monitor_reentry_duration_seconds_count{%s} %d\n", labels, h.Count)
+		}
+	}
+
+	header("monitor_long_running_total", "counter", "Long-running warnings per method.")
+	for _, group := range sortedKeys(s.longRunning) {
+		for _, method := range sortedKeys(s.longRunning[group]) {
+			fmt.Fprintf(&b, "monitor_long_running_total{group=%s,method=%s} %d\n", quoteLabel(group), quoteLabel(method), s.longRunning[group][method])
+		}
+	}
+
+	header("monitor_master_elections_total", "counter", "Master elections won per group.")
+	for _, group := range sortedKeys(s.elections) {
+		fmt.Fprintf(&b, "monitor_master_elections_total{group=%s} %d\n", quoteLabel(group), s.elections[group])
+	}
+
+	header("monitor_locker_operations_total", "counter", "Locker lock/refresh/unlock outcomes.")
+	for _, op := range sortedKeys(s.locker) {
+		for _, outcome := range sortedKeys(s.locker[op]) {
+			fmt.Fprintf(&b, "monitor_locker_operations_total{op=%s,outcome=%s} %d\n", quoteLabel(op), quoteLabel(outcome), s.locker[op][outcome])
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// quoteLabel 按 Prometheus 文本格式转义 label 值
+func quoteLabel(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,81 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_metrics"
+	ctx := context.TODO()
+	metrics := NewMetrics()
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+		WithWatchWarningTime(time.Millisecond*20),
+		WithMetrics(metrics),
+	)
+	m1.RegisterWithError("test_method", func(ctx context.Context, mctx MonitorContext) error {
+		body, _ := mctx.Get()
+		if string(body) == "fail" {
+			return errors.New("fail")
+		}
+		return nil
+	}, CallOpt{MaxAttempts: 1})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	// 心跳超时的节点遗留的任务，一个成功一个失败
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	for _, tag := range []string{"ok", "fail"} {
+		key := group + "|test_method|" + tag
+		assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+		assert.NoError(t, store.SetContext(ctx, key, []byte(tag), time.Minute))
+	}
+	// 长耗时任务
+	_, err := m1.Watch("test_method", "long", nil)
+	assert.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		snap := metrics.Snapshot()
+		return snap.Reentries[group]["test_method"].Count == 2 && snap.LongRunning[group]["test_method"] == 1
+	}, time.Second, time.Millisecond*10)
+	time.Sleep(time.Millisecond * 20) // 等待 master 上报任务数
+
+	snap := metrics.Snapshot()
+	assert.Equal(t, snap.Nodes[group], 1)
+	assert.Equal(t, snap.Watches[group], map[string]int{"test_method": 1})
+	assert.Equal(t, snap.Reentries[group]["test_method"].Failed, int64(1))
+	assert.Equal(t, snap.Elections[group], int64(1))
+	assert.Equal(t, snap.Locker["lock"]["success"], int64(1))
+
+	// Prometheus 文本格式
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, line := range []string{
+		`# TYPE monitor_nodes gauge`,
+		`monitor_nodes{group="test_monitor_metrics"} 1`,
+		`monitor_watches{group="test_monitor_metrics",method="test_method"} 1`,
+		`monitor_reentry_total{group="test_monitor_metrics",method="test_method",result="success"} 1`,
+		`monitor_reentry_total{group="test_monitor_metrics",method="test_method",result="error"} 1`,
+		`monitor_reentry_duration_seconds_bucket{group="test_monitor_metrics",method="test_method",le="+Inf"} 2`,
+		`monitor_reentry_duration_seconds_count{group="test_monitor_metrics",method="test_method"} 2`,
+		`monitor_long_running_total{group="test_monitor_metrics",method="test_method"} 1`,
+		`monitor_master_elections_total{group="test_monitor_metrics"} 1`,
+		`monitor_locker_operations_total{op="lock",outcome="success"} 1`,
+	} {
+		assert.Equal(t, strings.Contains(body, line+"\n"), true, line)
+	}
+	assert.Equal(t, quoteLabel("a\"b\\c\nd"), `"a\"b\\c\nd"`)
+}
@@ -0,0 +1,227 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// MigrateKeys 将旧版本的 redis key 迁移为当前格式，需在 group 内所有节点停止后执行
+// 旧版本使用 group:List、group:WatchList 等不带 hash tag 的 key，任务 key 未转义
+// 迁移后任务 key 按 BuildKey 转义，tag 中包含 | 的任务可以正确重入
+// prefix 不为空时同时迁移到 WithKeyPrefix 的命名空间，为空时保持默认命名空间
+// 中途失败可以重复执行，返回迁移的任务数
+func MigrateKeys(ctx context.Context, cli redis.UniversalClient, group string, prefix string) (count int, err error) {
+	s := &redisStore{cli: cli}
+	to := prefix + group
+	legacy := func(name string) string {
+		return group + ":" + name
+	}
+	newKey := func(key string) string {
+		return escapeKey(prefix) + migrateKey(key)
+	}
+
+	// 节点列表，同时迁移分配给节点的任务
+	nodes, err := cli.ZRangeWithScores(ctx, legacy("List"), 0, -1).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "[Migrate] List Error")
+	}
+	obsolete := []string{legacy("List"), legacy("WatchList"), legacy("WatchTime"), legacy("Attempts"), legacy("Lease"), legacy("Master")}
+	for _, z := range nodes {
+		uid := z.Member.(string)
+		if err = cli.ZAdd(ctx, s.groupList(to), &z).Err(); err != nil {
+			return 0, errors.Wrap(err, "[Migrate] List Error")
+		}
+		assigns, err := cli.HGetAll(ctx, legacy("Assign:"+uid)).Result()
+		if err != nil {
+			return 0, errors.Wrap(err, "[Migrate] Assign Error")
+		}
+		if err = migrateHash(ctx, cli, assigns, s.groupAssign(to, uid), newKey); err != nil {
+			return 0, errors.Wrap(err, "[Migrate] Assign Error")
+		}
+		obsolete = append(obsolete, legacy("Assign:"+uid))
+	}
+
+	// 任务列表与上下文
+	watches, err := cli.HGetAll(ctx, legacy("WatchList")).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "[Migrate] WatchList Error")
+	}
+	for key := range watches {
+		if err = migrateContext(ctx, s, key, newKey(key)); err != nil {
+			return 0, err
+		}
+	}
+	if err = migrateHash(ctx, cli, watches, s.groupWatchList(to), newKey); err != nil {
+		return 0, errors.Wrap(err, "[Migrate] WatchList Error")
+	}
+	for name, target := range map[string]string{"WatchTime": s.groupWatchTime(to), "Attempts": s.groupAttempts(to)} {
+		maps, err := cli.HGetAll(ctx, legacy(name)).Result()
+		if err != nil {
+			return 0, errors.Wrapf(err, "[Migrate] %s Error", name)
+		}
+		if err = migrateHash(ctx, cli, maps, target, newKey); err != nil {
+			return 0, errors.Wrapf(err, "[Migrate] %s Error", name)
+		}
+	}
+
+	// 租约
+	leases, err := cli.ZRangeWithScores(ctx, legacy("Lease"), 0, -1).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "[Migrate] Lease Error")
+	}
+	for _, z := range leases {
+		z.Member = newKey(z.Member.(string))
+		if err = cli.ZAdd(ctx, s.groupLease(to), &z).Err(); err != nil {
+			return 0, errors.Wrap(err, "[Migrate] Lease Error")
+		}
+	}
+
+	// 全部写入后再删除旧 key，master 由新版本节点重新上报
+	for _, key := range obsolete {
+		if err = cli.Del(ctx, key).Err(); err != nil {
+			return 0, errors.Wrap(err, "[Migrate] Del Error")
+		}
+	}
+	return len(watches), nil
+}
+
+// legacyStore 兼容旧版本不带 hash tag 的 key，只有 redisStore 实现
+// 滚动升级期间旧版本节点仍写入 group:List、group:WatchList，新版本的 master 接管其中节点已失效的任务
+// 使用 WithKeyPrefix 时不接管，需停止所有节点后执行 MigrateKeys
+type legacyStore interface {
+	adoptLegacyWatches(ctx context.Context, group string, deadline int64, timestamp int64) (count int, done bool, err error)
+}
+
+// adoptLegacyWatches 将旧版本任务列表中节点已失效的任务转为当前格式的无主任务，由 master 重入
+// 节点仍存活的任务留在旧任务列表中，由旧版本节点自行 Unwatch；心跳早于 deadline 的节点视为失效
+// 旧任务列表为空且没有存活的旧版本节点时 done 为 true，之后不再需要检查
+func (s *redisStore) adoptLegacyWatches(ctx context.Context, group string, deadline int64, timestamp int64) (count int, done bool, err error) {
+	legacy := group + ":WatchList"
+	watches, err := s.cli.HGetAll(ctx, legacy).Result()
+	if err != nil {
+		return 0, false, errors.Wrap(err, "[Migrate] WatchList Error")
+	}
+	alive, err := s.cli.ZRangeByScore(ctx, group+":List", &redis.ZRangeBy{
+		Min: strconv.FormatInt(deadline, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return 0, false, errors.Wrap(err, "[Migrate] List Error")
+	}
+	if len(watches) == 0 {
+		return 0, len(alive) == 0, nil
+	}
+	aliveMap := make(map[string]bool, len(alive))
+	for _, uid := range alive {
+		aliveMap[uid] = true
+	}
+
+	for key, uid := range watches {
+		if aliveMap[uid] {
+			continue
+		}
+		newKey := migrateKey(key)
+		// 新版本节点已 Watch 相同任务时保留新的上下文，只移除旧任务
+		has, err := s.cli.HExists(ctx, s.groupWatchList(group), newKey).Result()
+		if err != nil {
+			return count, false, errors.Wrap(err, "[Migrate] WatchList Error")
+		}
+		if !has {
+			if err = migrateContext(ctx, s, key, newKey); err != nil {
+				return count, false, err
+			}
+			if err = s.AddWatch(ctx, group, newKey, orphanUID, timestamp); err != nil {
+				return count, false, err
+			}
+			count++
+		}
+		if err = s.cli.HDel(ctx, legacy, key).Err(); err != nil {
+			return count, false, errors.Wrap(err, "[Migrate] WatchList Error")
+		}
+	}
+	return count, false, nil
+}
+
+// adoptLegacyWatches master 接管旧版本节点遗留的任务，存储不支持时不做任何操作
+// 旧版本节点全部下线且旧任务列表为空后停止检查
+func (m *monitorImpl) adoptLegacyWatches() {
+	ls, ok := m.store.(legacyStore)
+	if !ok || m.legacyDone {
+		return
+	}
+	now := m.clock.Now()
+	count, done, err := ls.adoptLegacyWatches(m.ctx, m.group, now.Add(-m.heartbeatTimeout).Unix(), now.Unix())
+	if err != nil {
+		log.Println("[Monitor] adoptLegacyWatches Error:", err)
+	}
+	if done {
+		m.legacyDone = true
+		log.Printf("[Monitor] adoptLegacyWatches finished, no legacy watches left. group: %s", m.group)
+	}
+	if count > 0 {
+		log.Printf("[Monitor] adoptLegacyWatches group: %s, count: %d", m.group, count)
+	}
+}
+
+// migrateKey 旧版本任务 key 未转义，method 之后的部分都属于 tag
+func migrateKey(key string) string {
+	arr := strings.Split(key, "|")
+	if len(arr) < 3 {
+		return key
+	}
+	return BuildKey(arr[0], arr[1], strings.Join(arr[2:], "|"))
+}
+
+// migrateHash 写入 key 转换后的 hash
+func migrateHash(ctx context.Context, cli redis.UniversalClient, maps map[string]string, target string, newKey func(string) string) error {
+	if len(maps) == 0 {
+		return nil
+	}
+	values := make([]any, 0, len(maps)*2)
+	for key, value := range maps {
+		values = append(values, newKey(key), value)
+	}
+	return cli.HSet(ctx, target, values...).Err()
+}
+
+// migrateContext 迁移上下文与步骤，保留剩余时长
+func migrateContext(ctx context.Context, s *redisStore, from string, to string) error {
+	body, err := s.cli.Get(ctx, from).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil // 上下文已过期或已迁移
+	}
+	if err != nil {
+		return errors.Wrap(err, "[Migrate] GetContext Error")
+	}
+	ttl, err := s.cli.PTTL(ctx, from).Result()
+	if err != nil {
+		return errors.Wrap(err, "[Migrate] ContextTTL Error")
+	}
+	if ttl < 0 {
+		ttl = 0 // 永不过期
+	}
+	if err = s.SetContext(ctx, to, body, ttl); err != nil {
+		return err
+	}
+	steps, err := s.cli.HGetAll(ctx, from+":Steps").Result()
+	if err != nil {
+		return errors.Wrap(err, "[Migrate] GetSteps Error")
+	}
+	for step, stepBody := range steps {
+		if err = s.SetStep(ctx, to, step, []byte(stepBody), ttl); err != nil {
+			return err
+		}
+	}
+	// 旧 key 不带 hash tag，分别删除避免跨 slot
+	for _, key := range []string{from, from + ":Steps"} {
+		if err = s.cli.Del(ctx, key).Err(); err != nil {
+			return errors.Wrap(err, "[Migrate] DelContext Error")
+		}
+	}
+	return nil
+}
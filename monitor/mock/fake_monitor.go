@@ -0,0 +1,744 @@
+package mock
+
+import (
+	"context"
+	stderrors "errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/FredyXue/go-utils"
+	"github.com/FredyXue/go-utils/monitor"
+	"github.com/pkg/errors"
+)
+
+// FakeUID FakeMonitor 的节点 id
+const FakeUID = "fake"
+
+// FakeMonitor 保存内存状态的 Monitor，用于业务层单元测试
+// 与 MockMonitor 不同，注册的 callback、Watch 的上下文、延时任务与死信都会被保存，所有调用按顺序记录
+// 通过 Crash、Reentry、Advance 模拟节点崩溃、立即重入与时间推进，测试无需 redis，也无需 sleep
+// callback 在调用 Crash、Reentry、Advance 的 goroutine 中同步执行。不执行 CallOpt.Retry，上下文不会过期
+type FakeMonitor struct {
+	mu        sync.Mutex
+	group     string
+	master    bool
+	shutting  bool
+	now       time.Time                            // 模拟时间，只由 Advance 推进
+	callbacks map[string]monitor.CallbackWithError // method -> callback
+	opts      map[string]monitor.CallOpt           // method -> CallOpt
+	crons     map[string]monitor.CronFunc          // name -> 定时任务
+	tasks     map[string]*fakeTask                 // key -> 任务列表中的任务
+	schedules map[string]*fakeSchedule             // key -> 延时任务
+	letters   map[string]*fakeLetter               // key -> 死信
+	calls     []Call
+	events    []monitor.Event
+}
+
+// Call 一次 Monitor 方法调用，XxxContext 与 Xxx 记录为同一个 Name
+type Call struct {
+	Name string
+	Args []any
+}
+
+// fakeTask 任务与上下文
+type fakeTask struct {
+	key        string
+	method     string
+	tag        string
+	owner      string // 执行节点，为空表示无主任务
+	body       []byte
+	deleted    bool                    // 上下文已删除
+	steps      map[string]monitor.Step // step -> Step
+	mctx       *FakeContext            // 执行中的上下文，无主任务为 nil
+	startAt    time.Time
+	escalation monitor.EscalationState
+	attempts   int64 // 重入次数，重新 Watch 时清零
+}
+
+type fakeSchedule struct {
+	task  *fakeTask
+	runAt time.Time
+}
+
+type fakeLetter struct {
+	task   *fakeTask
+	letter monitor.DeadLetter
+}
+
+var _ monitor.Monitor = (*FakeMonitor)(nil)
+
+// NewFakeMonitor 默认为 master，模拟时间从当前时间开始
+func NewFakeMonitor() *FakeMonitor {
+	return &FakeMonitor{
+		master:    true,
+		now:       time.Now(),
+		callbacks: make(map[string]monitor.CallbackWithError),
+		opts:      make(map[string]monitor.CallOpt),
+		crons:     make(map[string]monitor.CronFunc),
+		tasks:     make(map[string]*fakeTask),
+		schedules: make(map[string]*fakeSchedule),
+		letters:   make(map[string]*fakeLetter),
+	}
+}
+
+func (f *FakeMonitor) watchKey(method string, tag string) string {
+	return monitor.BuildKey(f.group, method, tag)
+}
+
+// record 记录调用，调用方需持有 f.mu
+func (f *FakeMonitor) record(name string, args ...any) {
+	f.calls = append(f.calls, Call{Name: name, Args: args})
+}
+
+// emit 记录事件，调用方需持有 f.mu
+func (f *FakeMonitor) emit(typ monitor.EventType, task *fakeTask) *monitor.Event {
+	e := monitor.Event{Type: typ, Group: f.group, UID: FakeUID, Time: f.now}
+	if task != nil {
+		e.Key, e.Method, e.Tag, e.Owner = task.key, task.method, task.tag, task.owner
+	}
+	f.events = append(f.events, e)
+	return &f.events[len(f.events)-1]
+}
+
+func (f *FakeMonitor) Start(group string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Start", group)
+	f.group = group
+}
+
+func (f *FakeMonitor) Stop() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Stop")
+}
+
+// Shutdown 之后不再接受新的 Watch，任务保留在任务列表中
+func (f *FakeMonitor) Shutdown(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Shutdown")
+	f.shutting = true
+	return nil
+}
+
+func (f *FakeMonitor) Register(method string, fn monitor.Callback, copt ...monitor.CallOpt) {
+	f.register("Register", method, func(ctx context.Context, mctx monitor.MonitorContext) error {
+		fn(mctx)
+		return nil
+	}, copt...)
+}
+
+func (f *FakeMonitor) RegisterContext(method string, fn monitor.CallbackContext, copt ...monitor.CallOpt) {
+	f.register("RegisterContext", method, func(ctx context.Context, mctx monitor.MonitorContext) error {
+		fn(ctx, mctx)
+		return nil
+	}, copt...)
+}
+
+func (f *FakeMonitor) RegisterWithError(method string, fn monitor.CallbackWithError, copt ...monitor.CallOpt) {
+	f.register("RegisterWithError", method, fn, copt...)
+}
+
+func (f *FakeMonitor) register(name string, method string, fn monitor.CallbackWithError, copt ...monitor.CallOpt) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record(name, method)
+	f.callbacks[method] = fn
+	opt := monitor.CallOpt{}
+	if len(copt) > 0 {
+		opt = copt[0]
+	}
+	opt.Escalations = monitor.SortEscalations(opt.Escalations)
+	f.opts[method] = opt
+}
+
+func (f *FakeMonitor) Deregister(method string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Deregister", method)
+	delete(f.callbacks, method)
+	delete(f.opts, method)
+}
+
+func (f *FakeMonitor) Watch(method string, tag string, ctxData ...[]byte) (mctx monitor.MonitorContext, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Watch", method, tag, ctxData)
+	if f.shutting {
+		return nil, monitor.ErrShuttingDown
+	}
+	if _, has := f.callbacks[method]; !has {
+		return nil, errors.Errorf("[Monitor] Watch Error: method %s is unregistered", method)
+	}
+	task := &fakeTask{
+		key:     f.watchKey(method, tag),
+		method:  method,
+		tag:     tag,
+		owner:   FakeUID,
+		steps:   make(map[string]monitor.Step),
+		startAt: f.now,
+	}
+	if len(ctxData) > 0 {
+		task.body = ctxData[0]
+	}
+	task.mctx = &FakeContext{f: f, task: task}
+	f.tasks[task.key] = task
+	return task.mctx, nil
+}
+
+func (f *FakeMonitor) WatchContext(ctx context.Context, method string, tag string, ctxData ...[]byte) (mctx monitor.MonitorContext, err error) {
+	return f.Watch(method, tag, ctxData...)
+}
+
+func (f *FakeMonitor) Unwatch(method string, tag string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Unwatch", method, tag)
+	f.removeTask(f.watchKey(method, tag))
+	return nil
+}
+
+func (f *FakeMonitor) UnwatchContext(ctx context.Context, method string, tag string) error {
+	return f.Unwatch(method, tag)
+}
+
+// removeTask 从任务列表移除并删除上下文，调用方需持有 f.mu
+func (f *FakeMonitor) removeTask(key string) *fakeTask {
+	task, has := f.tasks[key]
+	if !has {
+		return nil
+	}
+	delete(f.tasks, key)
+	task.deleted = true
+	task.steps = make(map[string]monitor.Step)
+	return task
+}
+
+func (f *FakeMonitor) WatchList() (list []string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("WatchList")
+	return f.sortedKeys(), nil
+}
+
+func (f *FakeMonitor) WatchListContext(ctx context.Context) (list []string, err error) {
+	return f.WatchList()
+}
+
+// sortedKeys 任务列表，调用方需持有 f.mu
+func (f *FakeMonitor) sortedKeys() []string {
+	list := make([]string, 0, len(f.tasks))
+	for key := range f.tasks {
+		list = append(list, key)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (f *FakeMonitor) IsMaster() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.master
+}
+
+// SetMaster 模拟角色切换
+func (f *FakeMonitor) SetMaster(isMaster bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.master = isMaster
+}
+
+// Schedule 延时任务，在 Advance 推进到 runAt 时重入
+func (f *FakeMonitor) Schedule(method string, tag string, runAt time.Time, data []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("Schedule", method, tag, runAt, data)
+	if _, has := f.callbacks[method]; !has {
+		return errors.Errorf("[Monitor] Schedule Error: method %s is unregistered", method)
+	}
+	key := f.watchKey(method, tag)
+	f.schedules[key] = &fakeSchedule{
+		task:  &fakeTask{key: key, method: method, tag: tag, body: data, steps: make(map[string]monitor.Step)},
+		runAt: runAt,
+	}
+	return nil
+}
+
+func (f *FakeMonitor) ScheduleContext(ctx context.Context, method string, tag string, runAt time.Time, data []byte) error {
+	return f.Schedule(method, tag, runAt, data)
+}
+
+// Cancel 移除任务，执行中的 mctx 被取消
+func (f *FakeMonitor) Cancel(method string, tag string) error {
+	f.mu.Lock()
+	f.record("Cancel", method, tag)
+	key := f.watchKey(method, tag)
+	task := f.removeTask(key)
+	if task == nil {
+		f.mu.Unlock()
+		return errors.Wrapf(monitor.ErrWatchNotFound, "key: %s", key)
+	}
+	mctx := task.mctx
+	if mctx != nil {
+		e := f.emit(monitor.EventWatchCanceled, task)
+		e.StartAt, e.Cost, e.Err = task.startAt, f.now.Sub(task.startAt), monitor.ErrCanceled
+	}
+	f.mu.Unlock()
+	if mctx != nil {
+		mctx.cancel(monitor.ErrCanceled)
+	}
+	return nil
+}
+
+func (f *FakeMonitor) CancelContext(ctx context.Context, method string, tag string) error {
+	return f.Cancel(method, tag)
+}
+
+// RegisterCron 保存定时任务，不解析 spec，通过 RunCron 执行
+func (f *FakeMonitor) RegisterCron(name string, spec string, fn monitor.CronFunc, opt ...monitor.CronOpt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("RegisterCron", name, spec)
+	f.crons[name] = fn
+	return nil
+}
+
+// RunCron 立即执行定时任务，runAt 为计划时间
+func (f *FakeMonitor) RunCron(name string, runAt time.Time) error {
+	f.mu.Lock()
+	fn, has := f.crons[name]
+	f.mu.Unlock()
+	if !has {
+		return errors.Errorf("[FakeMonitor] RunCron Error: cron %s is unregistered", name)
+	}
+	var err error
+	if panicErr := utils.ProtectV2(func() { err = fn(context.Background(), runAt) }); panicErr != nil {
+		err = panicErr
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := f.emit(monitor.EventCronFinished, nil)
+	e.Method, e.StartAt, e.Err = name, f.now, err
+	return err
+}
+
+func (f *FakeMonitor) DeadLetters(ctx context.Context) (list []monitor.DeadLetter, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("DeadLetters")
+	list = make([]monitor.DeadLetter, 0, len(f.letters))
+	for _, l := range f.letters {
+		list = append(list, l.letter)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return
+}
+
+// RequeueDeadLetter 重新加入任务列表，成为无主任务，重入次数从 0 开始
+func (f *FakeMonitor) RequeueDeadLetter(ctx context.Context, method string, tag string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("RequeueDeadLetter", method, tag)
+	key := f.watchKey(method, tag)
+	l, has := f.letters[key]
+	if !has {
+		return errors.Wrapf(monitor.ErrDeadLetterNotFound, "key: %s", key)
+	}
+	delete(f.letters, key)
+	l.task.attempts = 0
+	f.tasks[key] = l.task
+	return nil
+}
+
+func (f *FakeMonitor) PurgeDeadLetter(ctx context.Context, method string, tag string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.record("PurgeDeadLetter", method, tag)
+	key := f.watchKey(method, tag)
+	l, has := f.letters[key]
+	if !has {
+		return errors.Wrapf(monitor.ErrDeadLetterNotFound, "key: %s", key)
+	}
+	delete(f.letters, key)
+	l.task.deleted = true
+	return nil
+}
+
+// Crash 模拟节点崩溃后由其他节点接管：执行中的任务全部变为无主任务，并按 key 顺序立即重入
+// 崩溃节点上的 mctx 不会被取消，与进程退出一致。返回各任务重入的错误
+func (f *FakeMonitor) Crash() error {
+	f.mu.Lock()
+	for _, task := range f.tasks {
+		task.owner, task.mctx = "", nil
+	}
+	keys := f.sortedKeys()
+	f.mu.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		if err := f.reentry(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// Reentry 立即重入任务，不检查原节点是否存活
+func (f *FakeMonitor) Reentry(method string, tag string) error {
+	f.mu.Lock()
+	key := f.watchKey(method, tag)
+	f.mu.Unlock()
+	return f.reentry(key)
+}
+
+// reentry 与 monitor 的重入流程一致：计数、执行 callback，成功后解除监控，失败时保持为无主任务或移入死信列表
+func (f *FakeMonitor) reentry(key string) error {
+	f.mu.Lock()
+	task, has := f.tasks[key]
+	if !has {
+		f.mu.Unlock()
+		return errors.Wrapf(monitor.ErrWatchNotFound, "key: %s", key)
+	}
+	callback, has := f.callbacks[task.method]
+	if !has {
+		f.mu.Unlock()
+		return errors.Errorf("[FakeMonitor] Reentry Error: method %s is unregistered", task.method)
+	}
+	opt := f.opts[task.method]
+	f.emit(monitor.EventReentryStarted, task).StartAt = f.now
+	mctx := &FakeContext{f: f, task: task}
+	task.owner, task.mctx, task.startAt, task.escalation = FakeUID, mctx, f.now, monitor.EscalationState{}
+	task.attempts++
+	attempts := task.attempts
+	if opt.MaxAttempts > 0 && attempts > opt.MaxAttempts {
+		f.deadLetter(task, attempts-1, monitor.ErrTooManyAttempts)
+		f.finish(task, attempts, monitor.ErrTooManyAttempts)
+		f.mu.Unlock()
+		return monitor.ErrTooManyAttempts
+	}
+	f.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-mctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	var err error
+	if panicErr := utils.ProtectV2(func() { err = callback(ctx, mctx) }); panicErr != nil {
+		err = panicErr
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	defer f.finish(task, attempts, err)
+	if f.tasks[key] != task {
+		return err // 执行期间已被 Unwatch 或 Cancel
+	}
+	if err == nil {
+		f.removeTask(key)
+		return nil
+	}
+	if errors.Is(mctx.err, monitor.ErrWatchCanceled) {
+		// 耗时达到 EscalateCancel 级别，再次重入同样会被取消，移入死信列表
+		err = monitor.ErrWatchCanceled
+		f.deadLetter(task, attempts, err)
+		return err
+	}
+	if opt.MaxAttempts > 0 && attempts >= opt.MaxAttempts {
+		f.deadLetter(task, attempts, err)
+		return err
+	}
+	task.owner, task.mctx = "", nil // 保持监控，等待下一次重入
+	return err
+}
+
+// finish 记录重入结束事件，调用方需持有 f.mu
+func (f *FakeMonitor) finish(task *fakeTask, attempts int64, err error) {
+	e := f.emit(monitor.EventReentryFinished, task)
+	e.StartAt, e.Cost, e.Attempts, e.Err = task.startAt, f.now.Sub(task.startAt), attempts, err
+}
+
+// deadLetter 移入死信列表，上下文保留，调用方需持有 f.mu
+func (f *FakeMonitor) deadLetter(task *fakeTask, attempts int64, cause error) {
+	delete(f.tasks, task.key)
+	f.letters[task.key] = &fakeLetter{task: task, letter: monitor.DeadLetter{
+		Key:      task.key,
+		Method:   task.method,
+		Tag:      task.tag,
+		Owner:    task.owner,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		Time:     f.now,
+	}}
+	e := f.emit(monitor.EventDeadLetter, task)
+	e.Attempts, e.Err = attempts, cause
+}
+
+// Advance 推进模拟时间
+// 1. 执行中的任务按 CallOpt.Escalations 或 WatchWarningTime 升级，达到 EscalateCancel 级别时 mctx 被取消
+// 2. 到期的延时任务加入任务列表并立即重入，返回重入的错误
+func (f *FakeMonitor) Advance(d time.Duration) error {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	var cancels []*FakeContext
+	for _, key := range f.sortedKeys() {
+		task := f.tasks[key]
+		if task.mctx != nil && f.escalate(task) {
+			cancels = append(cancels, task.mctx)
+		}
+	}
+	var due []string
+	for key, s := range f.schedules {
+		if _, has := f.tasks[key]; has || s.runAt.After(f.now) {
+			continue // 相同 key 的任务仍在执行，或未到期
+		}
+		delete(f.schedules, key)
+		f.tasks[key] = s.task
+		f.emit(monitor.EventScheduleDue, s.task)
+		due = append(due, key)
+	}
+	f.mu.Unlock()
+
+	for _, mctx := range cancels {
+		mctx.cancel(monitor.ErrWatchCanceled)
+	}
+	sort.Strings(due)
+	var errs []error
+	for _, key := range due {
+		if err := f.reentry(key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return stderrors.Join(errs...)
+}
+
+// escalate 按 monitor 的升级规则检查任务，返回是否需要取消，调用方需持有 f.mu
+func (f *FakeMonitor) escalate(task *fakeTask) (cancel bool) {
+	if task.mctx.err != nil {
+		return false // 已取消
+	}
+	cost := f.now.Sub(task.startAt)
+	level, fired := task.escalation.Escalate(f.opts[task.method].EscalationLevels(0), cost, f.now)
+	if !fired {
+		return false
+	}
+
+	typ := monitor.EventLongRunning
+	if level.Action == monitor.EscalateCancel {
+		typ, cancel = monitor.EventWatchCanceled, true
+	}
+	e := f.emit(typ, task)
+	e.StartAt, e.Cost, e.Level, e.Action = task.startAt, cost, task.escalation.Level, level.Action
+	if cancel {
+		e.Err = monitor.ErrWatchCanceled
+	}
+	return
+}
+
+// Now 模拟时间
+func (f *FakeMonitor) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// Calls 按顺序返回全部调用
+func (f *FakeMonitor) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// CallCount 指定方法的调用次数
+func (f *FakeMonitor) CallCount(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	count := 0
+	for _, c := range f.calls {
+		if c.Name == name {
+			count++
+		}
+	}
+	return count
+}
+
+// Events 按顺序返回全部事件，Time 为模拟时间
+func (f *FakeMonitor) Events() []monitor.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]monitor.Event(nil), f.events...)
+}
+
+// Payload 任务的上下文，包括死信与未到期的延时任务，不存在时 has = false
+func (f *FakeMonitor) Payload(method string, tag string) (body []byte, has bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := f.watchKey(method, tag)
+	var task *fakeTask
+	if t, ok := f.tasks[key]; ok {
+		task = t
+	} else if l, ok := f.letters[key]; ok {
+		task = l.task
+	} else if s, ok := f.schedules[key]; ok {
+		task = s.task
+	}
+	if task == nil || task.deleted {
+		return nil, false
+	}
+	return task.body, true
+}
+
+// Owner 任务的执行节点，无主任务返回空字符串
+func (f *FakeMonitor) Owner(method string, tag string) (owner string, has bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	task, has := f.tasks[f.watchKey(method, tag)]
+	if !has {
+		return "", false
+	}
+	return task.owner, true
+}
+
+// FakeContext FakeMonitor 的 MonitorContext，数据保存在 FakeMonitor 中
+// 重入时创建新的 FakeContext，与原 mctx 共享上下文与步骤
+type FakeContext struct {
+	f    *FakeMonitor
+	task *fakeTask
+	done chan struct{}
+	err  error
+}
+
+// Get 上下文已删除时返回 ErrNil
+func (c *FakeContext) Get() ([]byte, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	if c.task.deleted {
+		return nil, errors.Wrap(monitor.ErrNil, "[MonitorContext] Get Error")
+	}
+	return c.task.body, nil
+}
+
+func (c *FakeContext) GetContext(ctx context.Context) ([]byte, error) {
+	return c.Get()
+}
+
+func (c *FakeContext) Set(body []byte) error {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.task.body, c.task.deleted = body, false
+	return nil
+}
+
+func (c *FakeContext) SetContext(ctx context.Context, body []byte) error {
+	return c.Set(body)
+}
+
+// Check 任务是否仍在任务列表中
+func (c *FakeContext) Check() (valid bool, err error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	return c.f.tasks[c.task.key] == c.task && !c.task.deleted, nil
+}
+
+// Close 删除上下文，任务同时从任务列表移除
+func (c *FakeContext) Close() error {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	if c.f.tasks[c.task.key] == c.task {
+		c.f.removeTask(c.task.key)
+	}
+	c.task.deleted = true
+	return nil
+}
+
+func (c *FakeContext) CloseContext(ctx context.Context) error {
+	return c.Close()
+}
+
+func (c *FakeContext) Renew() error {
+	return nil
+}
+
+func (c *FakeContext) KeepAlive(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c *FakeContext) Checkpoint(step string, status monitor.StepStatus, data []byte) error {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	c.task.steps[step] = monitor.Step{Name: step, Status: status, Data: data, UpdatedAt: c.f.now}
+	return nil
+}
+
+// Step 步骤不存在返回 ErrNil
+func (c *FakeContext) Step(step string) (monitor.Step, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	s, has := c.task.steps[step]
+	if !has {
+		return s, errors.Wrap(monitor.ErrNil, "[MonitorContext] Step Error")
+	}
+	return s, nil
+}
+
+// Steps 按更新时间排序，相同时按名称排序
+func (c *FakeContext) Steps() ([]monitor.Step, error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	list := make([]monitor.Step, 0, len(c.task.steps))
+	for _, s := range c.task.steps {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].UpdatedAt.Equal(list[j].UpdatedAt) {
+			return list[i].Name < list[j].Name
+		}
+		return list[i].UpdatedAt.Before(list[j].UpdatedAt)
+	})
+	return list, nil
+}
+
+func (c *FakeContext) Completed(step string) (bool, error) {
+	s, err := c.Step(step)
+	if errors.Is(err, monitor.ErrNil) {
+		return false, nil
+	}
+	return s.Status == monitor.StepCompleted, err
+}
+
+// Done 被 Cancel 或达到 EscalateCancel 级别时关闭
+func (c *FakeContext) Done() <-chan struct{} {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	return c.done
+}
+
+func (c *FakeContext) Err() error {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	return c.err
+}
+
+// cancel 取消任务，重复调用无效
+func (c *FakeContext) cancel(err error) {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	if c.done == nil {
+		c.done = make(chan struct{})
+	}
+	c.err = err
+	close(c.done)
+}
@@ -0,0 +1,131 @@
+package mock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/FredyXue/go-utils/monitor"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试节点崩溃后重入，失败的任务保持监控，超过最大次数移入死信列表
+func TestFakeMonitorCrash(t *testing.T) {
+	f := NewFakeMonitor()
+	var bodies []string
+	f.RegisterWithError("test_method", func(ctx context.Context, mctx monitor.MonitorContext) error {
+		body, err := mctx.Get()
+		assert.NoError(t, err)
+		bodies = append(bodies, string(body))
+		if done, _ := mctx.Completed("step1"); !done {
+			assert.NoError(t, mctx.Checkpoint("step1", monitor.StepCompleted, nil))
+			return errors.New("step2 failed")
+		}
+		return nil
+	}, monitor.CallOpt{MaxAttempts: 3})
+	f.Register("test_method2", func(mctx monitor.MonitorContext) {})
+	f.Start("test_fake")
+
+	mctx, err := f.Watch("test_method", "tag1", []byte("data1"))
+	assert.NoError(t, err)
+	assert.NoError(t, mctx.Set([]byte("data2")))
+	body, has := f.Payload("test_method", "tag1")
+	assert.Equal(t, []any{string(body), has}, []any{"data2", true})
+	_, err = f.Watch("unregistered", "tag1")
+	assert.Error(t, err)
+
+	assert.Error(t, f.Crash()) // 第一次重入失败，保持监控
+	owner, has := f.Owner("test_method", "tag1")
+	assert.Equal(t, []any{owner, has}, []any{"", true})
+	assert.NoError(t, f.Reentry("test_method", "tag1")) // 跳过已完成的步骤
+	assert.Equal(t, bodies, []string{"data2", "data2"})
+	list, _ := f.WatchList()
+	assert.Equal(t, len(list), 0)
+	valid, _ := mctx.Check()
+	assert.Equal(t, valid, false)
+
+	// 超过最大次数
+	f.RegisterWithError("test_method", func(ctx context.Context, mctx monitor.MonitorContext) error {
+		return errors.New("failed")
+	}, monitor.CallOpt{MaxAttempts: 2})
+	_, _ = f.Watch("test_method", "tag|2", []byte("data"))
+	assert.Error(t, f.Reentry("test_method", "tag|2"))
+	assert.Error(t, f.Reentry("test_method", "tag|2"))
+	letters, _ := f.DeadLetters(context.TODO())
+	assert.Equal(t, len(letters), 1)
+	assert.Equal(t, []any{letters[0].Key, letters[0].Tag, letters[0].Attempts}, []any{`test_fake|test_method|tag\|2`, "tag|2", int64(2)})
+	assert.ErrorIs(t, f.Reentry("test_method", "tag|2"), monitor.ErrWatchNotFound)
+	assert.NoError(t, f.RequeueDeadLetter(context.TODO(), "test_method", "tag|2"))
+	list, _ = f.WatchList()
+	assert.Equal(t, list, []string{`test_fake|test_method|tag\|2`})
+
+	assert.Equal(t, f.CallCount("Watch"), 3)
+	assert.Equal(t, f.Calls()[0], Call{Name: "RegisterWithError", Args: []any{"test_method"}})
+}
+
+// 测试推进时间触发长耗时预警、取消与延时任务
+func TestFakeMonitorAdvance(t *testing.T) {
+	f := NewFakeMonitor()
+	var runs []string
+	f.Register("test_method", func(mctx monitor.MonitorContext) {
+		body, _ := mctx.Get()
+		runs = append(runs, string(body))
+	}, monitor.CallOpt{
+		Escalations: []monitor.Escalation{
+			{After: time.Minute * 60, Action: monitor.EscalateCancel},
+			{After: time.Minute * 10, Action: monitor.EscalateWarn, Repeat: time.Minute * 5},
+		},
+	})
+	f.Start("test_fake")
+
+	mctx, _ := f.Watch("test_method", "tag1")
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, f.Advance(time.Minute*5))
+	}
+	assert.NoError(t, mctx.Err())
+	assert.NoError(t, f.Advance(time.Minute*31))
+	<-mctx.Done()
+	assert.ErrorIs(t, mctx.Err(), monitor.ErrWatchCanceled)
+
+	warns := 0
+	for _, e := range f.Events() {
+		if e.Type == monitor.EventLongRunning {
+			warns++
+		}
+	}
+	assert.Equal(t, warns, 4) // 15、20、25、30 分钟
+
+	assert.NoError(t, f.Schedule("test_method", "tag2", f.Now().Add(time.Hour), []byte("scheduled")))
+	assert.NoError(t, f.Advance(time.Minute*59))
+	assert.Equal(t, len(runs), 0)
+	assert.NoError(t, f.Advance(time.Minute))
+	assert.Equal(t, runs, []string{"scheduled"})
+
+	// 取消
+	mctx, _ = f.Watch("test_method", "tag3")
+	assert.NoError(t, f.Cancel("test_method", "tag3"))
+	assert.ErrorIs(t, mctx.Err(), monitor.ErrCanceled)
+	assert.ErrorIs(t, f.Cancel("test_method", "tag3"), monitor.ErrWatchNotFound)
+}
+
+// 测试重入时耗时达到 EscalateCancel 级别，任务移入死信列表
+func TestFakeMonitorReentryCanceled(t *testing.T) {
+	f := NewFakeMonitor()
+	f.RegisterWithError("test_method", func(ctx context.Context, mctx monitor.MonitorContext) error {
+		assert.NoError(t, f.Advance(time.Hour)) // 执行期间推进时间，触发取消
+		<-ctx.Done()
+		return ctx.Err()
+	}, monitor.CallOpt{
+		Escalations: []monitor.Escalation{{After: time.Minute * 30, Action: monitor.EscalateCancel}},
+	})
+	f.Start("test_fake")
+
+	_, _ = f.Watch("test_method", "tag1")
+	assert.ErrorIs(t, f.Reentry("test_method", "tag1"), monitor.ErrWatchCanceled)
+	letters, _ := f.DeadLetters(context.TODO())
+	assert.Equal(t, len(letters), 1)
+	assert.Equal(t, []any{letters[0].Tag, letters[0].Attempts, letters[0].Error}, []any{"tag1", int64(1), monitor.ErrWatchCanceled.Error()})
+	list, _ := f.WatchList()
+	assert.Equal(t, len(list), 0)
+}
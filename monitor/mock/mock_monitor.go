@@ -1,6 +1,11 @@
 package mock
 
-import "github.com/FredyXue/go-utils/monitor"
+import (
+	"context"
+	"time"
+
+	"github.com/FredyXue/go-utils/monitor"
+)
 
 var MockMonitor = NewMockMonitor()
 
@@ -12,37 +17,95 @@ type mockMonitor struct{}
 
 func (m *mockMonitor) Start(group string)                                                   {}
 func (m *mockMonitor) Stop()                                                                {}
+func (m *mockMonitor) Shutdown(ctx context.Context) error                                   { return nil }
 func (m *mockMonitor) Register(method string, fn monitor.Callback, copt ...monitor.CallOpt) {}
-func (m *mockMonitor) Deregister(method string)                                             {}
+func (m *mockMonitor) RegisterContext(method string, fn monitor.CallbackContext, copt ...monitor.CallOpt) {
+}
+func (m *mockMonitor) RegisterWithError(method string, fn monitor.CallbackWithError, copt ...monitor.CallOpt) {
+}
+func (m *mockMonitor) Deregister(method string) {}
 
 func (m *mockMonitor) Watch(method string, tag string, ctxData ...[]byte) (mctx monitor.MonitorContext, err error) {
 	mctx = &mockMonitorContext{}
 	return
 }
 
+func (m *mockMonitor) WatchContext(ctx context.Context, method string, tag string, ctxData ...[]byte) (mctx monitor.MonitorContext, err error) {
+	return m.Watch(method, tag, ctxData...)
+}
+
 func (m *mockMonitor) Unwatch(method string, tag string) error {
 	return nil
 }
 
+func (m *mockMonitor) UnwatchContext(ctx context.Context, method string, tag string) error {
+	return nil
+}
+
 func (m *mockMonitor) WatchList() (list []string, err error) {
 	list = make([]string, 0)
 	return
 }
 
+func (m *mockMonitor) WatchListContext(ctx context.Context) (list []string, err error) {
+	return m.WatchList()
+}
+
 func (m *mockMonitor) IsMaster() bool {
 	return true
 }
 
+func (m *mockMonitor) Schedule(method string, tag string, runAt time.Time, data []byte) error {
+	return nil
+}
+
+func (m *mockMonitor) ScheduleContext(ctx context.Context, method string, tag string, runAt time.Time, data []byte) error {
+	return m.Schedule(method, tag, runAt, data)
+}
+
+func (m *mockMonitor) Cancel(method string, tag string) error {
+	return nil
+}
+
+func (m *mockMonitor) CancelContext(ctx context.Context, method string, tag string) error {
+	return m.Cancel(method, tag)
+}
+
+func (m *mockMonitor) RegisterCron(name string, spec string, fn monitor.CronFunc, opt ...monitor.CronOpt) error {
+	return nil
+}
+
+func (m *mockMonitor) DeadLetters(ctx context.Context) (list []monitor.DeadLetter, err error) {
+	list = make([]monitor.DeadLetter, 0)
+	return
+}
+
+func (m *mockMonitor) RequeueDeadLetter(ctx context.Context, method string, tag string) error {
+	return monitor.ErrDeadLetterNotFound
+}
+
+func (m *mockMonitor) PurgeDeadLetter(ctx context.Context, method string, tag string) error {
+	return monitor.ErrDeadLetterNotFound
+}
+
 type mockMonitorContext struct{}
 
 func (c *mockMonitorContext) Get() ([]byte, error) {
 	return make([]byte, 0), nil
 }
 
+func (c *mockMonitorContext) GetContext(ctx context.Context) ([]byte, error) {
+	return c.Get()
+}
+
 func (c *mockMonitorContext) Set([]byte) error {
 	return nil
 }
 
+func (c *mockMonitorContext) SetContext(ctx context.Context, body []byte) error {
+	return nil
+}
+
 func (c *mockMonitorContext) Check() (valid bool, err error) {
 	return true, nil
 }
@@ -50,3 +113,41 @@ func (c *mockMonitorContext) Check() (valid bool, err error) {
 func (c *mockMonitorContext) Close() error {
 	return nil
 }
+
+func (c *mockMonitorContext) CloseContext(ctx context.Context) error {
+	return nil
+}
+
+func (c *mockMonitorContext) Renew() error {
+	return nil
+}
+
+func (c *mockMonitorContext) KeepAlive(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (c *mockMonitorContext) Checkpoint(step string, status monitor.StepStatus, data []byte) error {
+	return nil
+}
+
+func (c *mockMonitorContext) Step(step string) (monitor.Step, error) {
+	return monitor.Step{}, monitor.ErrNil
+}
+
+func (c *mockMonitorContext) Steps() ([]monitor.Step, error) {
+	return make([]monitor.Step, 0), nil
+}
+
+func (c *mockMonitorContext) Completed(step string) (bool, error) {
+	return false, nil
+}
+
+// Done 永不取消
+func (c *mockMonitorContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (c *mockMonitorContext) Err() error {
+	return nil
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/FredyXue/go-utils"
 	"github.com/FredyXue/go-utils/monitor/locker"
+	"github.com/FredyXue/go-utils/retry"
 	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
 	uuid "github.com/satori/go.uuid"
@@ -18,17 +21,42 @@ import (
 type Monitor interface {
 	Start(group string)                                                                  // 开启 monitor
 	Stop()                                                                               // 主动退出 monitor，否则等进程心跳超时才空出位置。
+	Shutdown(ctx context.Context) error                                                  // 优雅退出，等待重入任务结束，并将本地任务交给集群
 	Register(method string, fn Callback, copt ...CallOpt)                                // 注册 callback
+	RegisterContext(method string, fn CallbackContext, copt ...CallOpt)                  // 注册带 ctx 的 callback
+	RegisterWithError(method string, fn CallbackWithError, copt ...CallOpt)              // 注册返回 error 的 callback
 	Deregister(method string)                                                            // 注销 callback
 	Watch(method string, tag string, ctxData ...[]byte) (mctx MonitorContext, err error) // 任务监控
 	Unwatch(method string, tag string) error                                             // 任务完成，解除监控
 	WatchList() (list []string, err error)                                               // 存活任务列表
 	IsMaster() bool
+	Schedule(method string, tag string, runAt time.Time, data []byte) error   // 延时任务，到期后由 master 重入
+	RegisterCron(name string, spec string, fn CronFunc, opt ...CronOpt) error // 注册只在 master 上执行的定时任务
+	Cancel(method string, tag string) error                                   // 取消任务，执行中的节点 mctx.Done 关闭，不会再重入
+
+	WatchContext(ctx context.Context, method string, tag string, ctxData ...[]byte) (mctx MonitorContext, err error)
+	UnwatchContext(ctx context.Context, method string, tag string) error
+	WatchListContext(ctx context.Context) (list []string, err error)
+	ScheduleContext(ctx context.Context, method string, tag string, runAt time.Time, data []byte) error
+	CancelContext(ctx context.Context, method string, tag string) error
+
+	DeadLetters(ctx context.Context) (list []DeadLetter, err error)         // 死信列表
+	RequeueDeadLetter(ctx context.Context, method string, tag string) error // 死信重新加入任务列表，等待重入
+	PurgeDeadLetter(ctx context.Context, method string, tag string) error   // 删除死信与上下文
 }
 
+// ErrShuttingDown Shutdown 之后不再接受新的 Watch
+var ErrShuttingDown = errors.New("[Monitor] shutting down")
+
 // CallOpt
 type CallOpt struct {
-	WatchWarningTime time.Duration // watch 长耗时任务预警
+	WatchWarningTime time.Duration // watch 长耗时任务预警，只预警一次。配置了 Escalations 时忽略
+	Escalations      []Escalation  // 长耗时任务的多级升级，例如 10 分钟预警、30 分钟紧急预警、60 分钟取消
+	Retry            retry.Retry   // 重入 callback 返回 error 时的重试策略，默认不重试
+	MaxAttempts      int64         // 重入的最大次数，在重入开始时计数并持久化在存储中。超过后移入死信列表，0 表示不限制
+	MaxConcurrency   int           // 单个节点上该方法同时重入的最大数量，超过后排队，0 表示不限制
+	LeaseTTL         time.Duration // 任务租约时长，由执行节点通过 mctx.Renew/KeepAlive 续约。租约过期时即使节点存活也会重入，0 表示不使用租约
+	ReentryBackoff   time.Duration // 重入失败后到下一次重入的间隔，每失败一次翻倍，最多 64 倍。默认为心跳时间
 }
 
 // MOpt
@@ -58,73 +86,163 @@ func WithWatchWarningTime(watchWarningTime time.Duration) MOpt {
 	}
 }
 
+// WithAlertFunc 字符串预警
+// Deprecated: 使用 WithEventFunc 订阅类型化的事件
 func WithAlertFunc(alertFunc AlertFunc) MOpt {
 	return func(r *monitorImpl) {
 		r.alertFunc = alertFunc
 	}
 }
 
+// WithClock 时钟，默认系统时钟。同时用于选举使用的 Locker
+// 单元测试中注入可控的时钟后，心跳、选举、节点过期与长耗时预警只在时钟推进时发生
+// 存储中上下文的过期时间仍由存储计算
+func WithClock(clock utils.Clock) MOpt {
+	return func(r *monitorImpl) {
+		r.clock = clock
+	}
+}
+
+// WithMaxReentryConcurrency 单个节点同时重入的最大数量，超过后排队，在之后的心跳中执行。默认 0 不限制
+func WithMaxReentryConcurrency(max int) MOpt {
+	return func(r *monitorImpl) {
+		r.maxReentry = max
+	}
+}
+
+// WithLeaderFunc 角色切换回调，成为 master 时 isMaster = true，失去 master 时 isMaster = false
+// 业务层可以据此开启或停止仅在 master 上执行的逻辑
+func WithLeaderFunc(leaderFunc LeaderFunc) MOpt {
+	return func(r *monitorImpl) {
+		r.leaderFunc = leaderFunc
+	}
+}
+
 type Callback func(mctx MonitorContext)
+
+// CallbackContext 重入时传入的 ctx 会在 Stop、任务达到 EscalateCancel 级别或被 Cancel 时被取消
+type CallbackContext func(ctx context.Context, mctx MonitorContext)
+
+// CallbackWithError 返回 error 表示重入失败，任务保持监控，等待下一次重入
+type CallbackWithError func(ctx context.Context, mctx MonitorContext) error
 type AlertFunc func(msg string)
 
+// LeaderFunc 在 monitor 内部 goroutine 中同步调用，不应阻塞
+type LeaderFunc func(isMaster bool)
+
+// pendingReentry 超过并发上限，排队中的重入任务
+type pendingReentry struct {
+	mctx     MonitorContext
+	key      string
+	owner    string
+	assigned bool // master 分配给本节点的任务
+}
+
 type localWatch struct {
-	mctx      MonitorContext
-	startAt   time.Time // 任务开始时间
-	warnCount int       // 预警次数
-	method    string
+	mctx       MonitorContext
+	startAt    time.Time // 任务开始时间
+	escalation EscalationState
+	method     string
+	reentry    bool // 是否为重入任务
+	abandoned  bool // 租约过期，已放弃
+	canceled   bool // 达到 EscalateCancel 级别或被 Cancel，已取消
 }
 
 // monitorImpl .
+// mu 保护各类 map, role 使用原子操作。存储调用不持有锁。
 type monitorImpl struct {
 	once             sync.Once
+	mu               sync.RWMutex
 	ctx              context.Context
 	cancelCtx        context.Context
 	cancel           context.CancelFunc
+	loopDone         chan struct{} // 定时器循环结束
 	lock             locker.Locker
-	cli              *redis.Client
-	alertFunc        AlertFunc                 // 预警方法
-	uid              string                    // 节点 id
-	role             int32                     // 角色：0 worker 节点，1 master 节点
-	callbackMap      map[string]Callback       // method -> callback
-	watchWarningMap  map[string]time.Duration  // method -> WatchWarningTime 方法级长耗时预警
-	nodeMap          map[string]int64          // uid -> timestamp;   master 进程维护的节点列表
-	watchMap         map[string]MonitorContext // key -> mctx;   key = group|method|tag;  master 进程维护的 mctx 列表
-	localWatchMap    map[string]*localWatch    // key -> mctx;   本地进程维护的 mctx 列表;
-	group            string                    // 业务分组   STR
-	groupList        string                    // 节点列表   ZSET  uid -> timestamp
-	groupWatchList   string                    // 任务列表   HASH  key -> uid
-	heartbeatTime    time.Duration             // 心跳轮询时间
-	heartbeatTimeout time.Duration             // 心跳超时时间
-	watchTimeout     time.Duration             // watch 最大超时时间
-	watchWarningTime time.Duration             // watch 全局长耗时任务预警
-}
-
-func NewMonitor(cli *redis.Client, opts ...MOpt) Monitor {
+	store            Store
+	alertFunc        AlertFunc                    // 预警方法
+	eventFuncs       []EventFunc                  // 事件订阅
+	leaderFunc       LeaderFunc                   // 角色切换回调
+	reentryMode      ReentryMode                  // 重入任务的分配方式
+	metrics          *Metrics                     // 指标收集
+	codec            Codec                        // 上下文数据的编解码
+	keyPrefix        string                       // 存储 key 的前缀
+	clock            utils.Clock                  // 时钟
+	auditSink        AuditSink                    // 审计记录，nil 表示不记录
+	uid              string                       // 节点 id
+	role             int32                        // 角色：0 worker 节点，1 master 节点
+	leaderCtx        context.Context              // 成为 master 时创建，失去 master 时取消，m.mu 保护
+	leaderCancel     context.CancelFunc           // 取消 leaderCtx
+	callbackMap      map[string]CallbackWithError // method -> callback
+	callOptMap       map[string]CallOpt           // method -> CallOpt 方法级配置
+	crons            map[string]*cronJob          // name -> 定时任务
+	nodeMap          map[string]int64             // uid -> timestamp;   master 进程维护的节点列表
+	watchMap         map[string]MonitorContext    // key -> mctx;   key = group|method|tag;  master 进程维护的 mctx 列表
+	localWatchMap    map[string]*localWatch       // key -> mctx;   本地进程维护的 mctx 列表;
+	abandonedKeys    map[string]int               // key -> 已放弃、尚未调用 Unwatch 的任务数
+	running          map[string]int               // method -> 正在重入的数量
+	runningTotal     int                          // 正在重入的总数
+	pending          []pendingReentry             // 排队中的重入任务，FIFO
+	pendingKeys      map[string]bool              // 排队中的 key
+	maxReentry       int                          // 同时重入的最大数量
+	reentryWg        sync.WaitGroup               // 正在执行的重入任务
+	shutting         bool                         // Shutdown 中，不再接受新的 Watch 与重入
+	cancelSub        <-chan struct{}              // 取消信号的订阅，结束时关闭，nil 表示未订阅。只在定时器 goroutine 中访问
+	subscribeFails   int                          // 连续订阅失败的次数
+	subscribeRetryAt time.Time                    // 订阅失败后下一次订阅的时间
+	legacyDone       bool                         // 旧版本的任务已全部接管，不再检查。只在定时器 goroutine 中访问
+	group            string                       // 业务分组   STR
+	heartbeatTime    time.Duration                // 心跳轮询时间
+	heartbeatTimeout time.Duration                // 心跳超时时间
+	watchTimeout     time.Duration                // watch 最大超时时间
+	watchWarningTime time.Duration                // watch 全局长耗时任务预警
+}
+
+// NewMonitor 使用 redis 存储，支持 *redis.Client、*redis.ClusterClient 等 redis.UniversalClient
+func NewMonitor(cli redis.UniversalClient, opts ...MOpt) Monitor {
+	return NewMonitorWithStore(NewRedisStore(cli), opts...)
+}
+
+// NewMonitorWithStore 使用指定的存储后端
+func NewMonitorWithStore(store Store, opts ...MOpt) Monitor {
 	m := &monitorImpl{
 		ctx:              context.Background(),
-		cli:              cli,
+		store:            store,
 		role:             0,
-		callbackMap:      make(map[string]Callback),
-		watchWarningMap:  make(map[string]time.Duration),
+		callbackMap:      make(map[string]CallbackWithError),
+		callOptMap:       make(map[string]CallOpt),
+		crons:            make(map[string]*cronJob),
 		nodeMap:          make(map[string]int64),
 		watchMap:         make(map[string]MonitorContext),
 		localWatchMap:    make(map[string]*localWatch),
+		abandonedKeys:    make(map[string]int),
+		running:          make(map[string]int),
+		pendingKeys:      make(map[string]bool),
 		heartbeatTime:    time.Minute,     // 默认心跳 1 分钟轮询
 		heartbeatTimeout: time.Minute * 3, // 默认心跳超时 3 分钟
 		watchTimeout:     time.Hour * 2,   // 默认 watch 最大超时 2h
 		watchWarningTime: 0,               // 默认全局不预警长耗时任务
+		loopDone:         make(chan struct{}),
+		codec:            JSONCodec,
+		clock:            utils.RealClock,
 	}
 	for _, o := range opts {
 		o(m)
 	}
+	m.store = NewPrefixStore(m.store, m.keyPrefix)
+	m.cancelCtx, m.cancel = context.WithCancel(context.Background()) // 建立 cancel ctx
 	m.uid = strings.ReplaceAll(uuid.NewV4().String(), "-", "")
 
-	// 使用 RedisLocker 作为心跳工具
-	m.lock = locker.NewRedisLocker(cli,
+	// 使用 Store 提供的 Locker 作为心跳工具
+	lockOpts := []locker.Option{
 		locker.WithRefreshTime(m.heartbeatTime),
 		locker.WithLockTime(m.heartbeatTimeout),
-		locker.WithExpiredTime(time.Duration(1<<63-1)), // maxDuration
-	)
+		locker.WithExpiredTime(time.Duration(1<<63 - 1)), // maxDuration
+		locker.WithClock(m.clock),
+	}
+	if m.metrics != nil {
+		lockOpts = append(lockOpts, locker.WithObserver(m.metrics.LockerObserver()))
+	}
+	m.lock = m.store.NewLocker(lockOpts...)
 	return m
 }
 
@@ -132,25 +250,41 @@ func NewMonitor(cli *redis.Client, opts ...MOpt) Monitor {
 // 先注册 callback, 然后 Start
 func (m *monitorImpl) Start(group string) {
 	tickerRun := func() {
-		m.heartbeat()           // 心跳
+		if m.cancelCtx.Err() != nil {
+			return // 已 Stop
+		}
+		m.heartbeat()       // 心跳
+		m.subscribeCancel() // 订阅取消信号
+		if m.isShutting() {
+			return // Shutdown 中，只维持心跳，避免本地任务被提前重入
+		}
 		m.checkLocalWatchList() // 检测本地任务
-		if m.role == 0 {
+		m.runPending()          // 执行排队中的重入任务
+		if m.reentryMode != ReentryLocal {
+			m.checkAssignments() // 领取 master 分配的重入任务
+		}
+		verified := true
+		if m.IsMaster() {
+			verified = m.checkMaster() // master 角色，检测是否仍持有锁
+		}
+		if !m.IsMaster() {
 			m.election() // worker 角色，选举
 		}
-		if m.role == 1 {
+		if m.IsMaster() && verified {
+			m.reportMaster()   // master 记录 uid，供 Admin 查询
 			m.checkNodeList()  // master 检测节点列表
+			m.checkSchedules() // master 将到期的延时任务加入任务列表
+			m.checkCrons()     // master 执行到期的定时任务
 			m.checkWatchList() // master 检测任务列表
 		}
 	}
 
 	m.once.Do(func() {
 		m.group = group
-		m.groupList = group + ":List"
-		m.groupWatchList = group + ":WatchList"
 
 		go utils.Protect(func() {
-			m.cancelCtx, m.cancel = context.WithCancel(context.TODO()) // 建立 cancel ctx
-			ticker := time.NewTicker(m.heartbeatTime)
+			defer close(m.loopDone)
+			ticker := m.clock.NewTicker(m.heartbeatTime)
 			defer ticker.Stop()
 
 			tickerRun() // 首次立刻执行
@@ -159,7 +293,7 @@ func (m *monitorImpl) Start(group string) {
 		tickerLabel:
 			for {
 				select {
-				case <-ticker.C:
+				case <-ticker.C():
 					tickerRun()
 				case <-m.cancelCtx.Done():
 					break tickerLabel // ctx canceled 结束循环
@@ -171,25 +305,92 @@ func (m *monitorImpl) Start(group string) {
 
 // Stop 主动退出 monitor，否则等进程心跳超时才空出位置。
 func (m *monitorImpl) Stop() {
-	m.cancel()      // 取消定时器
-	m.lock.Unlock() // 即使未加锁，解锁也不会报错
-	m.role = 0      // 恢复为 worker
+	m.cancel() // 取消定时器
+	m.once.Do(func() { close(m.loopDone) })
+	<-m.loopDone            // 等待正在执行的定时任务结束
+	m.lock.Unlock()         // 即使未加锁，解锁也不会报错
+	m.setMaster(false, nil) // 恢复为 worker
 
 	// 从节点列表移除
-	if err := m.cli.ZRem(m.ctx, m.groupList, m.uid).Err(); err != nil {
-		log.Println("[Monitor] Logout ZRem Error:", err)
+	if err := m.store.RemoveNode(m.ctx, m.group, m.uid); err != nil {
+		log.Println("[Monitor] Logout RemoveNode Error:", err)
 	}
 
 	log.Printf("[Monitor] Stop. group: %s, uid: %s", m.group, m.uid)
 }
 
+// Shutdown 优雅退出
+// 1. 不再接受新的 Watch 与重入，master 立即释放锁，由其他节点选举
+// 2. 等待正在执行的重入任务结束，直到 ctx 超时
+// 3. 剩余的本地任务标记为无主任务，由新 master 立即重入，无需等待心跳超时
+// 4. Stop
+// ctx 超时时返回 ctx.Err()，剩余步骤仍会执行
+func (m *monitorImpl) Shutdown(ctx context.Context) (err error) {
+	m.mu.Lock()
+	m.shutting = true
+	m.mu.Unlock()
+	if m.IsMaster() {
+		m.lock.Unlock()
+		m.setMaster(false, nil)
+	}
+
+	// 等待重入任务结束
+	waitDone := make(chan struct{})
+	go utils.Protect(func() {
+		m.reentryWg.Wait()
+		close(waitDone)
+	})
+	select {
+	case <-waitDone:
+	case <-ctx.Done():
+		err = ctx.Err()
+		log.Printf("[Monitor] Shutdown wait reentry Error: %v", err)
+	}
+
+	// 剩余的本地任务与排队中的任务，交给集群
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.localWatchMap)+len(m.pending))
+	for key, lw := range m.localWatchMap {
+		if errors.Is(lw.mctx.Err(), ErrCanceled) {
+			continue // 已被 Cancel，不再交给集群
+		}
+		keys = append(keys, key)
+	}
+	for _, p := range m.pending {
+		keys = append(keys, p.key)
+	}
+	m.pending = nil
+	m.pendingKeys = make(map[string]bool)
+	m.mu.Unlock()
+	// 只释放仍属于本节点的任务，已完成、已移除或被其他节点接管的任务不受影响
+	handoff := 0
+	for _, key := range keys {
+		released, rerr := m.store.ReleaseWatch(m.ctx, m.group, key, m.uid, m.clock.Now())
+		if rerr != nil {
+			log.Printf("[Monitor] Shutdown ReleaseWatch key: %s, Error: %v", key, rerr)
+			continue
+		}
+		if released {
+			handoff++
+		}
+	}
+	if handoff > 0 {
+		log.Printf("[Monitor] Shutdown hand off %d watches. group: %s, uid: %s", handoff, m.group, m.uid)
+	}
+
+	m.Stop()
+	return
+}
+
+func (m *monitorImpl) isShutting() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.shutting
+}
+
 // heartbeat 维护节点心跳
 func (m *monitorImpl) heartbeat() {
-	z := &redis.Z{
-		Score:  float64(time.Now().Unix()),
-		Member: m.uid,
-	}
-	if err := m.cli.ZAdd(m.ctx, m.groupList, z).Err(); err != nil {
+	if err := m.store.Heartbeat(m.ctx, m.group, m.uid, m.clock.Now().Unix()); err != nil {
 		log.Println("[Monitor] heartbeat Error:", err)
 	}
 }
@@ -202,8 +403,63 @@ func (m *monitorImpl) election() {
 		return
 	}
 	if success {
-		m.role = 1 // 升级为 master
 		log.Println("[Monitor] election master success:", m.group, m.uid)
+		m.setMaster(true, nil) // 升级为 master
+	}
+}
+
+// checkMaster 检测 master 是否仍持有锁
+// 网络分区或者长时间 GC 导致续约失败时，锁可能已被其他节点抢占，此时降级为 worker，避免出现两个 master
+// return false 表示本轮无法确认锁的归属，跳过 master 的检测任务
+func (m *monitorImpl) checkMaster() bool {
+	_, owner, err := m.lock.Check(m.group)
+	if err != nil {
+		log.Println("[Monitor] checkMaster Check Error:", err)
+		return false
+	}
+	if owner {
+		return true
+	}
+
+	log.Println("[Monitor] lost master lock, step down:", m.group, m.uid)
+	m.lock.Unlock() // 清理本地加锁状态，锁已不属于当前节点，不会误删
+	m.mu.Lock()
+	m.nodeMap = make(map[string]int64)
+	m.watchMap = make(map[string]MonitorContext)
+	m.mu.Unlock()
+	m.setMaster(false, ErrMasterLost)
+	return false
+}
+
+// reportMaster 记录当前 master 的 uid，心跳超时后过期
+func (m *monitorImpl) reportMaster() {
+	if err := m.store.SetMaster(m.ctx, m.group, m.uid, m.heartbeatTimeout); err != nil {
+		log.Println("[Monitor] reportMaster SetMaster Error:", err)
+	}
+}
+
+// setMaster 切换角色，并触发事件与回调
+func (m *monitorImpl) setMaster(isMaster bool, reason error) {
+	role, typ := int32(0), EventMasterLost
+	if isMaster {
+		role, typ = 1, EventMasterElected
+	}
+	if atomic.SwapInt32(&m.role, role) == role {
+		return // 角色未变化
+	}
+	m.mu.Lock()
+	if m.leaderCancel != nil {
+		m.leaderCancel()
+	}
+	m.leaderCtx, m.leaderCancel = nil, nil
+	if isMaster {
+		m.leaderCtx, m.leaderCancel = context.WithCancel(m.cancelCtx)
+	}
+	m.mu.Unlock()
+
+	m.emit(Event{Type: typ, Owner: m.uid, Err: reason})
+	if m.leaderFunc != nil {
+		m.leaderFunc(isMaster)
 	}
 }
 
@@ -212,56 +468,113 @@ func (m *monitorImpl) election() {
 // callback 执行失败，不会再次重入。业务层需要自己处理好执行失败的逻辑，例如重试。
 // 重入只解决进程崩溃导致的任务丢失。
 func (m *monitorImpl) Register(method string, fn Callback, copt ...CallOpt) {
-	m.callbackMap[method] = fn
+	m.RegisterContext(method, func(ctx context.Context, mctx MonitorContext) { fn(mctx) }, copt...)
+}
 
-	// 方法级配置
-	if len(copt) == 0 {
-		return
-	}
-	opt := copt[0]
+// RegisterContext 注册带 ctx 的 callback
+// 重入时传入的 ctx 会在 Stop 或任务达到 EscalateCancel 级别时被取消，业务层可以据此中断执行
+func (m *monitorImpl) RegisterContext(method string, fn CallbackContext, copt ...CallOpt) {
+	m.RegisterWithError(method, func(ctx context.Context, mctx MonitorContext) error {
+		fn(ctx, mctx)
+		return nil
+	}, copt...)
+}
 
-	// 长耗时预警
-	if opt.WatchWarningTime > 0 {
-		m.watchWarningMap[method] = opt.WatchWarningTime
+// RegisterWithError 注册返回 error 的 callback
+// callback 返回 error 时，先按 CallOpt.Retry 在本地重试；仍然失败则保持监控，等待下一次重入。
+// 重入次数持久化在存储中，超过 CallOpt.MaxAttempts 后移入死信列表并预警。
+func (m *monitorImpl) RegisterWithError(method string, fn CallbackWithError, copt ...CallOpt) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.callbackMap[method] = fn
+
+	// 方法级配置，重新注册且未指定时清除之前的配置
+	delete(m.callOptMap, method)
+	if len(copt) > 0 {
+		opt := copt[0]
+		opt.Escalations = SortEscalations(opt.Escalations)
+		m.callOptMap[method] = opt
 	}
 }
 
 // Deregister 注销 callback
 func (m *monitorImpl) Deregister(method string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	delete(m.callbackMap, method)
+	delete(m.callOptMap, method)
 }
 
 // Watch 任务监控
-// 存储 key = group|method|tag
+// 存储 key = group|method|tag，各段中的 \ 与 | 转义，见 BuildKey
 // return ctx 上下文
 func (m *monitorImpl) Watch(method string, tag string, ctxData ...[]byte) (mctx MonitorContext, err error) {
+	return m.WatchContext(m.ctx, method, tag, ctxData...)
+}
+
+// WatchContext 任务监控，ctx 用于本次调用的存储操作
+// 返回 error 时任务已从任务列表回滚，不会被重入
+func (m *monitorImpl) WatchContext(ctx context.Context, method string, tag string, ctxData ...[]byte) (mctx MonitorContext, err error) {
+	m.mu.RLock()
 	_, has := m.callbackMap[method]
+	shutting := m.shutting
+	m.mu.RUnlock()
+	if shutting {
+		err = ErrShuttingDown
+		return
+	}
 	if !has {
 		err = errors.Errorf("[Monitor] Watch Error: method %s is unregistered", method)
 		return
 	}
 
-	key := m.group + "|" + method + "|" + tag
+	key := m.watchKey(method, tag)
 
 	// add to watchList
-	if err = m.cli.HSet(m.ctx, m.groupWatchList, key, m.uid).Err(); err != nil {
-		err = errors.Wrap(err, "[Monitor] Watch HSet Error")
+	if err = m.store.AddWatch(ctx, m.group, key, m.uid, m.clock.Now().Unix()); err != nil {
+		err = errors.Wrap(err, "[Monitor] Watch AddWatch Error")
 		return
 	}
+	// 后续步骤失败时回滚，避免 master 重入调用方认为已失败的任务
+	defer func() {
+		if err == nil {
+			return
+		}
+		m.mu.Lock()
+		if lw, has := m.localWatchMap[key]; has && lw.mctx == mctx {
+			delete(m.localWatchMap, key)
+		}
+		m.mu.Unlock()
+		if rerr := m.store.RemoveWatch(ctx, m.group, key); rerr != nil {
+			log.Printf("[Monitor] Watch rollback RemoveWatch key: %s, Error: %v", key, rerr)
+		}
+		m.audit(ctx, AuditRecord{Action: AuditUnwatch, Key: key, Err: err.Error()})
+		mctx = nil
+	}()
+
 	// new 上下文。原始 mctx, 任务首次 watch 时使用。
-	mctx = NewMonitorContext(m.cli, key, m.watchTimeout)
+	mctx = m.newContext(key)
+	if err = mctx.Renew(); err != nil {
+		err = errors.Wrap(err, "[Monitor] Watch Renew Error")
+		return
+	}
 	// for unwatch close
+	m.mu.Lock()
+	delete(m.abandonedKeys, key) // 重新 watch，之前放弃的任务视为已结束
 	m.localWatchMap[key] = &localWatch{
 		mctx:    mctx,
-		startAt: time.Now(),
+		startAt: m.clock.Now(),
 		method:  method,
 	}
+	m.mu.Unlock()
+	m.audit(ctx, AuditRecord{Action: AuditWatch, Key: key})
 
 	// 设置上下文数据
 	if len(ctxData) > 0 {
-		err = mctx.Set(ctxData[0])
-		err = errors.Wrap(err, "[Monitor] Watch mctx.Set Error")
-		return
+		if err = mctx.SetContext(ctx, ctxData[0]); err != nil {
+			err = errors.Wrap(err, "[Monitor] Watch mctx.Set Error")
+			return
+		}
 	}
 
 	return
@@ -269,81 +582,200 @@ func (m *monitorImpl) Watch(method string, tag string, ctxData ...[]byte) (mctx
 
 // Unwatch 任务完成，解除监控
 func (m *monitorImpl) Unwatch(method string, tag string) (err error) {
-	key := m.group + "|" + method + "|" + tag
-	// remove from watchList
-	if err = m.cli.HDel(m.ctx, m.groupWatchList, key).Err(); err != nil {
-		return errors.Wrap(err, "[Monitor] Unwatch HDel Error")
-	}
-	// 清理上下文
+	return m.UnwatchContext(m.ctx, method, tag)
+}
+
+// UnwatchContext 任务完成，解除监控
+// 本地任务已因租约过期、被接管或被 Cancel 而放弃时，不做任何操作，不影响新的执行
+func (m *monitorImpl) UnwatchContext(ctx context.Context, method string, tag string) (err error) {
+	key := m.watchKey(method, tag)
+	m.mu.Lock()
 	lw, has := m.localWatchMap[key]
-	if !has {
+	stale := m.abandonedKeys[key] > 0
+	if stale {
+		if m.abandonedKeys[key]--; m.abandonedKeys[key] == 0 {
+			delete(m.abandonedKeys, key)
+		}
+	}
+	m.mu.Unlock()
+	if stale {
+		log.Printf("[Monitor] Unwatch ignored, MonitorContext was abandoned, key: %s", key)
 		return
 	}
-	if err = lw.mctx.Close(); err != nil {
-		return errors.Wrap(err, "[Monitor] Unwatch Close Error")
+	if has {
+		return m.unwatchLocal(ctx, key, lw)
+	}
+
+	// 任务不在本节点执行，直接移除
+	if err = m.store.RemoveWatch(ctx, m.group, key); err != nil {
+		return errors.Wrap(err, "[Monitor] Unwatch RemoveWatch Error")
+	}
+	m.audit(ctx, AuditRecord{Action: AuditUnwatch, Key: key}) // 没有执行时长
+	return
+}
+
+// unwatchLocal 本地任务完成，任务仍属于本节点时移除并清理上下文
+// 任务已被其他节点接管时，只移除本地记录，上下文属于新的执行
+func (m *monitorImpl) unwatchLocal(ctx context.Context, key string, lw *localWatch) (err error) {
+	removed, err := m.store.RemoveWatchIf(ctx, m.group, key, m.uid)
+	if err != nil {
+		return errors.Wrap(err, "[Monitor] Unwatch RemoveWatch Error")
+	}
+	if removed {
+		if err = lw.mctx.CloseContext(ctx); err != nil {
+			return errors.Wrap(err, "[Monitor] Unwatch Close Error")
+		}
+	} else {
+		log.Printf("[Monitor] Unwatch skipped, watch removed or taken over, key: %s", key)
+	}
+	m.mu.Lock()
+	if m.localWatchMap[key] == lw {
+		delete(m.localWatchMap, key)
+	}
+	m.mu.Unlock()
+	if removed {
+		m.audit(ctx, AuditRecord{Action: AuditUnwatch, Key: key, Cost: m.clock.Since(lw.startAt)})
 	}
-	delete(m.localWatchMap, key)
 	return
 }
 
 // checkLocalWatchList 检测本地任务状态
 func (m *monitorImpl) checkLocalWatchList() {
-	// 未设置长耗时任务预警，直接跳过
-	if m.watchWarningTime == 0 && len(m.watchWarningMap) == 0 {
-		return
-	}
+	var msgs []string
+	var events []Event
+	var cancels []MonitorContext
+	defer func() {
+		for _, mctx := range cancels {
+			if c, ok := mctx.(canceler); ok {
+				c.cancel(ErrWatchCanceled)
+			}
+		}
+		for i, e := range events {
+			m.alert(msgs[i]) // 预警长耗时任务，不持有锁
+			m.emit(e)
+		}
+	}()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	for key, lw := range m.localWatchMap {
-		warningTime := m.watchWarningTime
-		methodWaringTime, has := m.watchWarningMap[lw.method]
-		if has {
-			warningTime = methodWaringTime
+		// 租约过期，任务可能已卡住，放弃执行，由 master 重入
+		if lh, ok := lw.mctx.(leaseHolder); ok && lh.leaseExpired() {
+			m.abandonLocked(key, lw)
+			msg := fmt.Sprintf("[Monitor] abandon lease expired MonitorContext cost: %v, key: %s", m.clock.Since(lw.startAt), key)
+			log.Println(msg)
+			msgs = append(msgs, msg)
+			e := taskEvent(EventLeaseExpired, key, m.uid)
+			e.StartAt, e.Cost = lw.startAt, m.clock.Since(lw.startAt)
+			events = append(events, e)
+			continue
 		}
 
-		// 超时 且 未预警的任务
-		if time.Since(lw.startAt) > warningTime && lw.warnCount == 0 {
-			msg := fmt.Sprintf("[Monitor] find executed too long MonitorContext cost: %v, key: %s", time.Since(lw.startAt), key)
-			log.Println(msg)
-			m.alert(msg) // 预警长耗时任务
-			lw.warnCount++
+		// 长耗时任务逐级升级
+		if msg, e, fired := m.escalate(key, lw, m.clock.Now()); fired {
+			msgs = append(msgs, msg)
+			events = append(events, e)
+			if lw.canceled {
+				cancels = append(cancels, lw.mctx)
+			}
 		}
 	}
 }
 
+// abandonLocked 放弃本地任务，mctx 尚未取消时以 ErrLeaseExpired 取消，通知仍在执行的代码尽快结束，调用方持有 m.mu
+// 业务层 Watch 的任务之后调用的 Unwatch 被忽略；重入任务结束时不再解除监控
+func (m *monitorImpl) abandonLocked(key string, lw *localWatch) {
+	delete(m.localWatchMap, key)
+	if lw.reentry {
+		m.running[lw.method]--
+		m.runningTotal--
+	} else {
+		m.abandonedKeys[key]++
+	}
+	lw.abandoned = true
+	if c, ok := lw.mctx.(canceler); ok {
+		c.cancel(ErrLeaseExpired) // 只持有 mctx 的锁，不会与 m.mu 死锁
+	}
+}
+
+// abandonSuperseded 任务已不属于本节点，例如被 Admin.ForceReentry 标记为无主任务，放弃本地任务以便重新分配
+// 只有 master 会执行该方法，其他节点上被接管的任务在 Unwatch 时发现
+func (m *monitorImpl) abandonSuperseded(key string, owner string) {
+	m.mu.Lock()
+	lw, has := m.localWatchMap[key]
+	if has {
+		m.abandonLocked(key, lw)
+	}
+	m.mu.Unlock()
+	if has {
+		log.Printf("[Monitor] abandon superseded MonitorContext key: %s, owner: %q", key, owner)
+	}
+}
+
 // WatchList 存活任务列表
 // return group|method|tag
 func (m *monitorImpl) WatchList() (list []string, err error) {
-	list, err = m.cli.HKeys(m.ctx, m.groupWatchList).Result()
+	return m.WatchListContext(m.ctx)
+}
+
+// WatchListContext 存活任务列表
+func (m *monitorImpl) WatchListContext(ctx context.Context) (list []string, err error) {
+	maps, err := m.store.WatchList(ctx, m.group)
 	if err != nil {
 		err = errors.Wrap(err, "[Monitor] WatchList Error")
 		return
 	}
+	list = make([]string, 0, len(maps))
+	for key := range maps {
+		list = append(list, key)
+	}
+	sort.Strings(list)
 	return
 }
 
 // checkNodeList 检测节点列表
 func (m *monitorImpl) checkNodeList() {
-	listZ, err := m.cli.ZRangeWithScores(m.ctx, m.groupList, 0, -1).Result()
+	nodes, err := m.store.NodeList(m.ctx, m.group)
 	if err != nil {
-		log.Println("[Monitor] checkNodeList ZRangeWithScores Error:", err)
+		log.Println("[Monitor] checkNodeList NodeList Error:", err)
 		return
 	}
 
-	m.nodeMap = make(map[string]int64) // 重新初始化
-	for _, z := range listZ {
-		timestamp := int64(z.Score)
-		uid := z.Member.(string)
+	m.mu.RLock()
+	oldNodeMap := m.nodeMap
+	m.mu.RUnlock()
 
+	var events []Event
+	nodeMap := make(map[string]int64) // 重新初始化
+	for uid, timestamp := range nodes {
+		lastAt := time.Unix(timestamp, 0)
 		// now - heartbeatTimeout < timestamp 心跳未超时
-		if uid != "" && time.Now().Add(-m.heartbeatTimeout).Unix() < timestamp {
-			m.nodeMap[uid] = timestamp
+		if uid != "" && m.clock.Now().Add(-m.heartbeatTimeout).Unix() < timestamp {
+			nodeMap[uid] = timestamp
+			// 首次检测时 oldNodeMap 为空，不视为新节点加入
+			if _, has := oldNodeMap[uid]; !has && len(oldNodeMap) > 0 {
+				events = append(events, Event{Type: EventNodeJoined, Owner: uid, StartAt: lastAt})
+			}
 		} else {
 			// 从节点列表移除
-			if err := m.cli.ZRem(m.ctx, m.groupList, uid).Err(); err != nil {
-				log.Println("[Monitor] checkNodeList ZRem Error:", err)
+			if err := m.store.RemoveNode(m.ctx, m.group, uid); err != nil {
+				log.Println("[Monitor] checkNodeList RemoveNode Error:", err)
 			}
+			events = append(events, Event{Type: EventNodeExpired, Owner: uid, StartAt: lastAt, Cost: m.clock.Since(lastAt)})
 		}
 	}
+
+	m.mu.Lock()
+	m.nodeMap = nodeMap
+	m.mu.Unlock()
+	if m.metrics != nil {
+		m.metrics.setNodes(m.group, len(nodeMap))
+	}
+
+	for _, e := range events {
+		m.emit(e)
+	}
 }
 
 // checkWatchList 检测任务列表
@@ -351,19 +783,59 @@ func (m *monitorImpl) checkNodeList() {
 // 构建 watchMap, 超时的 mctx，会 close
 // 判断节点丢失的 mctx，会发起任务重入
 func (m *monitorImpl) checkWatchList() {
-	maps, err := m.cli.HGetAll(m.ctx, m.groupWatchList).Result()
+	m.adoptLegacyWatches()
+	maps, err := m.store.WatchList(m.ctx, m.group)
 	if err != nil {
-		log.Println("[Monitor] checkWatchList HGetAll Error:", err)
+		log.Println("[Monitor] checkWatchList WatchList Error:", err)
 		return
 	}
 
-	oldWatchMap := m.watchMap
-	m.watchMap = make(map[string]MonitorContext) // 重新初始化
+	expired := make(map[string]bool)
+	if keys, err := m.store.ExpiredLeases(m.ctx, m.group, m.clock.Now()); err != nil {
+		log.Println("[Monitor] checkWatchList ExpiredLeases Error:", err)
+	} else {
+		for _, key := range keys {
+			expired[key] = true
+		}
+	}
+	delayed := make(map[string]bool) // 重入失败，尚未到下一次重入时间
+	if keys, err := m.store.DelayedWatches(m.ctx, m.group, m.clock.Now()); err != nil {
+		log.Println("[Monitor] checkWatchList DelayedWatches Error:", err)
+	} else {
+		for _, key := range keys {
+			delayed[key] = true
+		}
+	}
+
+	m.mu.RLock()
+	oldWatchMap, nodeMap := m.watchMap, m.nodeMap
+	m.mu.RUnlock()
+
+	var bal *balancer
+	if m.reentryMode != ReentryLocal {
+		bal = newBalancer(m.reentryMode, nodeMap, maps)
+	}
+
+	watchMap := make(map[string]MonitorContext) // 重新初始化
+	defer func() {
+		m.mu.Lock()
+		m.watchMap = watchMap
+		m.mu.Unlock()
+		if m.metrics != nil {
+			counts := make(map[string]int)
+			for key := range watchMap {
+				if _, method, _, ok := ParseKey(key); ok {
+					counts[method]++
+				}
+			}
+			m.metrics.setWatches(m.group, counts)
+		}
+	}()
 	for key, uid := range maps {
 		mctx, has := oldWatchMap[key]
 		if !has {
 			// 构建新的 mctx
-			mctx = NewMonitorContext(m.cli, key, m.watchTimeout)
+			mctx = m.newContext(key)
 		}
 
 		valid, err := mctx.Check()
@@ -376,73 +848,391 @@ func (m *monitorImpl) checkWatchList() {
 			msg := fmt.Sprintf("[Monitor] remove invalid MonitorContext key: %s", key)
 			log.Println(msg)
 			m.alert(msg) // 触发移除 mctx 时，预警
+			m.emit(taskEvent(EventWatchExpired, key, uid))
+			m.audit(m.ctx, AuditRecord{Action: AuditExpire, Key: key, Owner: uid})
 
 			if err = mctx.Close(); err != nil {
 				log.Println(err)
 			}
-			if err = m.cli.HDel(m.ctx, m.groupWatchList, key).Err(); err != nil {
-				log.Println("[Monitor] checkWatchList HDel Error:", err)
+			if err = m.store.RemoveWatch(m.ctx, m.group, key); err != nil {
+				log.Println("[Monitor] checkWatchList RemoveWatch Error:", err)
 			}
 			continue
 		}
 
-		m.watchMap[key] = mctx // 存储有效的 mctx
+		watchMap[key] = mctx // 存储有效的 mctx
 
-		if _, has = m.nodeMap[uid]; !has {
-			// 节点丢失，触发任务重入
-			m.reentry(mctx, key)
+		// 本地执行中的任务被其他节点接管或标记为无主任务
+		if uid != m.uid {
+			m.abandonSuperseded(key, uid)
+		}
+
+		if _, has = nodeMap[uid]; (!has || expired[key]) && !delayed[key] {
+			// 节点丢失或租约过期，触发任务重入
+			m.dispatch(bal, mctx, key, uid)
 		}
 	}
 }
 
-// reentry 任务重入
-func (m *monitorImpl) reentry(mctx MonitorContext, key string) {
-	// 判断是否正在重入
-	_, has := m.localWatchMap[key]
-	if has {
+// dispatch 分配丢失的任务
+// bal 为空时 master 本地重入，否则分配给存活节点，由节点在下一次心跳时领取
+func (m *monitorImpl) dispatch(bal *balancer, mctx MonitorContext, key string, owner string) {
+	m.mu.RLock()
+	_, running := m.localWatchMap[key]
+	running = running || m.pendingKeys[key]
+	m.mu.RUnlock()
+	if running {
+		return // master 正在重入或排队中
+	}
+
+	target := m.uid
+	if bal != nil {
+		if uid := bal.pick(key); uid != "" {
+			target = uid
+		}
+	}
+	if target == m.uid {
+		m.reentry(mctx, key, owner, false)
 		return
 	}
 
-	msg := fmt.Sprintf("[Monitor] execute reentry MonitorContext key: %s", key)
-	log.Println(msg)
-	m.alert(msg) // 触发重入时，预警
+	assigned, err := m.store.Assign(m.ctx, m.group, key, owner, target)
+	if err != nil {
+		log.Printf("[Monitor] dispatch Assign key: %s, Error: %v", key, err)
+		return
+	}
+	if !assigned {
+		log.Printf("[Monitor] dispatch skipped, watch removed or taken over, key: %s", key)
+		return
+	}
+	// 续约，避免在目标节点领取前重复分配
+	if err := mctx.Renew(); err != nil {
+		log.Printf("[Monitor] dispatch Renew key: %s, Error: %v", key, err)
+	}
+	log.Printf("[Monitor] assign reentry MonitorContext key: %s, target: %s", key, target)
+	e := taskEvent(EventReentryAssigned, key, owner)
+	e.Target = target
+	m.emit(e)
+}
 
-	arr := strings.Split(key, "|")
-	if len(arr) <= 2 {
+// checkAssignments 领取 master 分配的重入任务，在本地执行
+func (m *monitorImpl) checkAssignments() {
+	assigns, err := m.store.Assignments(m.ctx, m.group, m.uid)
+	if err != nil {
+		log.Println("[Monitor] checkAssignments Assignments Error:", err)
+		return
+	}
+	for key, owner := range assigns {
+		m.reentry(m.newContext(key), key, owner, true)
+	}
+}
+
+// releaseAssigned 放弃 master 分配给本节点的任务，仍属于本节点时标记为无主任务，由 master 重新分配
+func (m *monitorImpl) releaseAssigned(key string) {
+	if _, err := m.store.ReleaseWatch(m.ctx, m.group, key, m.uid, m.clock.Now()); err != nil {
+		log.Printf("[Monitor] reentry ReleaseWatch key: %s, Error: %v", key, err)
+	}
+}
+
+// reentry 任务重入
+// owner 为任务原来所属的节点，assigned 表示任务由 master 分配给本节点，放弃执行时需要释放
+func (m *monitorImpl) reentry(mctx MonitorContext, key string, owner string, assigned bool) {
+	_, method, _, ok := ParseKey(key)
+	if !ok {
 		log.Printf("[Monitor] checkWatchList invalide key: %s", key)
+		if assigned {
+			m.releaseAssigned(key)
+		}
+		return
+	}
+
+	// 判断是否正在重入或排队，并加入 localWatch
+	m.mu.Lock()
+	if _, has := m.localWatchMap[key]; has || m.pendingKeys[key] {
+		m.mu.Unlock()
+		return
+	}
+	if m.shutting {
+		m.mu.Unlock()
+		if assigned {
+			m.releaseAssigned(key)
+		}
 		return
 	}
-	method, tag := arr[1], arr[2]
 	callback, has := m.callbackMap[method]
 	if !has {
+		m.mu.Unlock()
 		log.Printf("[Monitor] checkWatchList callback method not found: %s", method)
+		if assigned {
+			m.releaseAssigned(key)
+		}
+		return
+	}
+	opt := m.callOptMap[method]
+	// 超过并发上限，排队
+	if (m.maxReentry > 0 && m.runningTotal >= m.maxReentry) || (opt.MaxConcurrency > 0 && m.running[method] >= opt.MaxConcurrency) {
+		m.pending = append(m.pending, pendingReentry{mctx: mctx, key: key, owner: owner, assigned: assigned})
+		m.pendingKeys[key] = true
+		m.mu.Unlock()
+		log.Printf("[Monitor] reentry queued, key: %s", key)
+		return
+	}
+	m.running[method]++
+	m.runningTotal++
+	m.reentryWg.Add(1)
+	lw := &localWatch{
+		mctx:    mctx,
+		startAt: m.clock.Now(),
+		method:  method,
+		reentry: true,
+	}
+	m.localWatchMap[key] = lw
+	m.mu.Unlock()
+
+	// 认领任务，任务已被移除或由其他节点执行时放弃
+	if claimed, err := m.store.ClaimWatch(m.ctx, m.group, key, owner, m.uid); err != nil || !claimed {
+		if err != nil {
+			log.Printf("[Monitor] reentry ClaimWatch key: %s, Error: %v", key, err)
+		} else {
+			log.Printf("[Monitor] reentry skipped, watch removed or taken over, key: %s", key)
+		}
+		m.mu.Lock()
+		if m.localWatchMap[key] == lw {
+			delete(m.localWatchMap, key)
+		}
+		m.running[method]--
+		m.runningTotal--
+		m.mu.Unlock()
+		m.reentryWg.Done()
+		if assigned {
+			m.releaseAssigned(key)
+		}
 		return
 	}
 
+	// 续约，避免 master 在执行期间重复分配
+	if err := mctx.Renew(); err != nil {
+		log.Printf("[Monitor] reentry Renew key: %s, Error: %v", key, err)
+	}
+
+	msg := fmt.Sprintf("[Monitor] execute reentry MonitorContext key: %s", key)
+	log.Println(msg)
+	m.alert(msg) // 触发重入时，预警
+	e := taskEvent(EventReentryStarted, key, owner)
+	e.StartAt = lw.startAt
+	m.emit(e)
+
 	// 执行任务重入
 	go utils.Protect(func() {
-		// 加入 localWatch
-		m.localWatchMap[key] = &localWatch{
-			mctx:    mctx,
-			startAt: time.Now(),
-			method:  method,
+		defer m.reentryWg.Done()
+		finished := taskEvent(EventReentryFinished, key, owner)
+		finished.StartAt = lw.startAt
+		defer func() {
+			finished.Cost = m.clock.Since(lw.startAt)
+			m.emit(finished)
+		}()
+
+		// 重入失败，在 localWatch 移除之后释放任务，等待 master 下一次重入
+		var attempts int64
+		release := false
+		defer func() {
+			m.mu.RLock()
+			abandoned := lw.abandoned
+			m.mu.RUnlock()
+			if release && !abandoned {
+				m.releaseWatch(key, opt, attempts)
+			}
+		}()
+
+		// finally 从 localWatch 移除。
+		defer func() {
+			m.mu.Lock()
+			if m.localWatchMap[key] == lw {
+				delete(m.localWatchMap, key)
+			}
+			if !lw.abandoned {
+				m.running[method]--
+				m.runningTotal--
+			}
+			m.mu.Unlock()
+		}()
+
+		// 重入次数在开始时计数，节点崩溃或 callback panic 也会被计入
+		attempts = m.incrAttempts(key)
+		finished.Attempts = attempts
+		m.audit(m.ctx, AuditRecord{Action: AuditReentry, Key: key, Owner: owner, Attempts: attempts})
+		if opt.MaxAttempts > 0 && attempts > opt.MaxAttempts {
+			finished.Err = ErrTooManyAttempts
+			m.deadLetter(key, attempts-1, ErrTooManyAttempts)
+			return
 		}
-		defer delete(m.localWatchMap, key) // finally 从 localWatch 移除。
 
 		// callback 在异常情况下，可能会被调用多次。业务层需要做好相应的幂等操作。
-		// callback 执行失败，不会再次重入。业务层需要自己处理好执行失败的逻辑，例如重试。
-		// 重入只解决进程崩溃导致的任务丢失。
-		callback(mctx)
+		// callback 返回 error 时，任务保持监控，等待下一次重入。
+		ctx, cancel := m.taskContext(mctx)
+		defer cancel()
+		if err := m.call(ctx, callback, opt, mctx); err != nil {
+			finished.Err = err
+			log.Printf("[Monitor] reentry failed key: %s, attempts: %d, Error: %v", key, attempts, err)
+			if errors.Is(mctx.Err(), ErrCanceled) {
+				return // 已被 Cancel 移出任务列表，不再重入
+			}
+			if errors.Is(mctx.Err(), ErrLeaseExpired) {
+				return // 租约过期已被放弃，由 master 重新分配，不计入死信
+			}
+			if errors.Is(mctx.Err(), ErrWatchCanceled) {
+				// 耗时达到 EscalateCancel 级别，再次重入同样会被取消，移入死信列表
+				m.mu.RLock()
+				abandoned := lw.abandoned
+				m.mu.RUnlock()
+				if !abandoned {
+					finished.Err = ErrWatchCanceled
+					m.deadLetter(key, attempts, ErrWatchCanceled)
+				}
+				return
+			}
+			if opt.MaxAttempts > 0 && attempts >= opt.MaxAttempts {
+				m.deadLetter(key, attempts, err)
+				return
+			}
+			release = true // 保持监控，标记为无主任务，等待 master 下一次重入
+			return
+		}
 
-		if err := m.Unwatch(method, tag); err != nil {
+		m.mu.RLock()
+		abandoned := lw.abandoned
+		m.mu.RUnlock()
+		if abandoned {
+			return // 租约过期已被重新分配，不影响新的执行
+		}
+		if err := m.unwatchLocal(m.ctx, key, lw); err != nil {
 			log.Printf("[Monitor] reentry Error: %v", err) // 报错直接返回，等待下一次重入
 			return
 		}
 	})
 }
 
+// runPending 按排队顺序执行重入任务，仍然超过并发上限的任务继续排队
+func (m *monitorImpl) runPending() {
+	m.mu.Lock()
+	pending := m.pending
+	m.pending = nil
+	m.pendingKeys = make(map[string]bool)
+	m.mu.Unlock()
+
+	for _, p := range pending {
+		// 排队期间任务已完成或被移除
+		if valid, err := p.mctx.Check(); err == nil && !valid {
+			continue
+		}
+		m.reentry(p.mctx, p.key, p.owner, p.assigned)
+	}
+}
+
+// call 执行 callback，配置了 Retry 时按重试策略执行
+func (m *monitorImpl) call(ctx context.Context, callback CallbackWithError, opt CallOpt, mctx MonitorContext) error {
+	if opt.Retry == nil {
+		return safeCall(callback, ctx, mctx)
+	}
+	return opt.Retry.Do(func() error {
+		if err := ctx.Err(); err != nil {
+			return err // 已 Stop 或任务被取消，不再重试
+		}
+		return safeCall(callback, ctx, mctx)
+	})
+}
+
+// safeCall callback panic 时转换为 error，按重入失败处理
+func safeCall(callback CallbackWithError, ctx context.Context, mctx MonitorContext) (err error) {
+	if panicErr := utils.ProtectV2(func() { err = callback(ctx, mctx) }); panicErr != nil {
+		return panicErr
+	}
+	return
+}
+
+// incrAttempts 重入次数 +1，失败时返回 0，不限制本次重入
+func (m *monitorImpl) incrAttempts(key string) int64 {
+	attempts, err := m.store.IncrAttempts(m.ctx, m.group, key)
+	if err != nil {
+		log.Printf("[Monitor] reentry IncrAttempts key: %s, Error: %v", key, err)
+	}
+	return attempts
+}
+
+// maxBackoffShift 重入间隔与订阅重试间隔最多翻倍的次数
+const maxBackoffShift = 6
+
+// releaseWatch 任务仍属于本节点时标记为无主任务，按失败次数退避，到期后由 master 重入
+// 任务已被移除（例如 Admin.ForceUnwatch、死信操作）或已由其他节点接管时不做任何操作
+func (m *monitorImpl) releaseWatch(key string, opt CallOpt, attempts int64) {
+	backoff := opt.ReentryBackoff
+	if backoff <= 0 {
+		backoff = m.heartbeatTime
+	}
+	if shift := attempts - 1; shift > 0 {
+		if shift > maxBackoffShift {
+			shift = maxBackoffShift
+		}
+		backoff <<= shift
+	}
+	released, err := m.store.ReleaseWatch(m.ctx, m.group, key, m.uid, m.clock.Now().Add(backoff))
+	if err != nil {
+		log.Printf("[Monitor] reentry ReleaseWatch key: %s, Error: %v", key, err)
+		return
+	}
+	if !released {
+		log.Printf("[Monitor] reentry release skipped, watch removed or taken over, key: %s", key)
+	}
+}
+
+// orphanUID 无主任务的节点 id，不会出现在节点列表中
+const orphanUID = ""
+
+// watchKey key = group|method|tag，各段转义见 BuildKey
+func (m *monitorImpl) watchKey(method string, tag string) string {
+	return BuildKey(m.group, method, tag)
+}
+
+// newContext 创建任务的 mctx，方法配置了租约时携带租约信息
+func (m *monitorImpl) newContext(key string) MonitorContext {
+	var leaseTTL time.Duration
+	if _, method, _, ok := ParseKey(key); ok {
+		m.mu.RLock()
+		leaseTTL = m.callOptMap[method].LeaseTTL
+		m.mu.RUnlock()
+	}
+	return &monitorContext{
+		ctx:         context.TODO(),
+		store:       m.store,
+		codec:       m.codec,
+		group:       m.group,
+		key:         key,
+		clock:       m.clock,
+		audit:       m.audit,
+		leaseTTL:    leaseTTL,
+		expiredDur:  m.watchTimeout,
+		expiredTime: m.clock.Now().Add(m.watchTimeout),
+	}
+}
+
+func (m *monitorImpl) getCodec() Codec {
+	return m.codec
+}
+
 func (m *monitorImpl) IsMaster() bool {
-	return m.role == 1
+	return atomic.LoadInt32(&m.role) == 1
+}
+
+// leaderContext 本次担任 master 期间的 ctx，失去 master 或 Stop 时取消。不是 master 时返回已取消的 ctx
+func (m *monitorImpl) leaderContext() context.Context {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.leaderCtx != nil {
+		return m.leaderCtx
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
 }
 
 func (m *monitorImpl) alert(msg string) {
@@ -461,22 +1251,53 @@ type MonitorContext interface {
 	Set([]byte) error
 	Check() (valid bool, err error)
 	Close() error
+
+	GetContext(ctx context.Context) ([]byte, error)
+	SetContext(ctx context.Context, body []byte) error
+	CloseContext(ctx context.Context) error
+
+	Renew() error                        // 续约任务租约，未配置租约时不做任何操作
+	KeepAlive(ctx context.Context) error // 定期续约，直到 ctx 结束或 mctx 被 Close
+
+	Checkpoint(step string, status StepStatus, data []byte) error // 记录步骤
+	Step(step string) (Step, error)                               // 获取步骤，不存在返回 ErrNil
+	Steps() ([]Step, error)                                       // 全部步骤，按更新时间排序
+	Completed(step string) (bool, error)                          // 步骤是否已完成
+
+	Done() <-chan struct{} // 任务被取消时关闭，见 CallOpt.Escalations 与 Monitor.Cancel
+	Err() error            // 取消原因，未取消时返回 nil
 }
 
 type monitorContext struct {
+	mu          sync.Mutex
 	ctx         context.Context
-	cli         *redis.Client
+	store       Store
+	codec       Codec
+	group       string
 	key         string
+	clock       utils.Clock
+	audit       func(ctx context.Context, r AuditRecord) // 记录 Set，可以为 nil
 	closed      bool
 	expiredDur  time.Duration
 	expiredTime time.Time
+	leaseTTL    time.Duration // 租约时长，0 表示不使用租约
+	leaseExpire time.Time     // 本地记录的租约过期时间
+	done        chan struct{} // 任务取消时关闭，延迟创建
+	err         error         // 取消原因
+}
+
+func NewMonitorContext(cli redis.UniversalClient, key string, expiredDur time.Duration) MonitorContext {
+	return NewMonitorContextWithStore(NewRedisStore(cli), key, expiredDur)
 }
 
-func NewMonitorContext(cli *redis.Client, key string, expiredDur time.Duration) MonitorContext {
+// NewMonitorContextWithStore 使用指定的存储后端
+func NewMonitorContextWithStore(store Store, key string, expiredDur time.Duration) MonitorContext {
 	return &monitorContext{
 		ctx:         context.TODO(),
-		cli:         cli,
+		store:       store,
+		codec:       JSONCodec,
 		key:         key,
+		clock:       utils.RealClock,
 		expiredDur:  expiredDur,
 		expiredTime: time.Now().Add(expiredDur),
 	}
@@ -484,39 +1305,57 @@ func NewMonitorContext(cli *redis.Client, key string, expiredDur time.Duration)
 
 // Get .
 func (c *monitorContext) Get() (body []byte, err error) {
-	rlt, err := c.cli.Get(c.ctx, c.key).Result()
-	if err != nil {
-		err = errors.Wrap(err, "[MonitorContext] Get Error")
-		return
-	}
-	body = []byte(rlt)
+	return c.GetContext(c.ctx)
+}
+
+// GetContext .
+func (c *monitorContext) GetContext(ctx context.Context) (body []byte, err error) {
+	body, err = c.store.GetContext(ctx, c.key)
+	err = errors.Wrap(err, "[MonitorContext] Get Error")
 	return
 }
 
+func (c *monitorContext) getCodec() Codec {
+	return c.codec
+}
+
 // Set 支持中途多次更新上下文
 // 为了保留 key, 允许设置空 value
 func (c *monitorContext) Set(body []byte) error {
+	return c.SetContext(c.ctx, body)
+}
+
+// SetContext 支持中途多次更新上下文
+func (c *monitorContext) SetContext(ctx context.Context, body []byte) error {
 	// if len(body) == 0 {
 	// 	return nil
 	// }
-	c.expiredTime = time.Now().Add(c.expiredDur) // 更新超时时间
-	err := c.cli.Set(c.ctx, c.key, body, c.expiredDur).Err()
+	c.mu.Lock()
+	c.expiredTime = c.clock.Now().Add(c.expiredDur) // 更新超时时间
+	c.mu.Unlock()
+	err := c.store.SetContext(ctx, c.key, body, c.expiredDur)
+	if err == nil && c.audit != nil {
+		c.audit(ctx, AuditRecord{Action: AuditSet, Key: c.key, Size: len(body)})
+	}
 	return errors.Wrap(err, "[MonitorContext] Set Error")
 }
 
 // check 检验 mctx 是否有效
 func (c *monitorContext) Check() (valid bool, err error) {
-	if c.closed {
+	c.mu.Lock()
+	closed, expiredTime := c.closed, c.expiredTime
+	c.mu.Unlock()
+	if closed {
 		return
 	}
 
 	// 超时直接清理
-	if time.Now().After(c.expiredTime) {
+	if c.clock.Now().After(expiredTime) {
 		err = c.Close()
 		return
 	}
 
-	ttl, err := c.cli.TTL(c.ctx, c.key).Result()
+	ttl, err := c.store.ContextTTL(c.ctx, c.key)
 	if err != nil {
 		err = errors.Wrap(err, "[MonitorContext] check TTL Error")
 		return
@@ -529,9 +1368,16 @@ func (c *monitorContext) Check() (valid bool, err error) {
 
 // Close 清理上下文
 func (c *monitorContext) Close() (err error) {
-	if err = c.cli.Del(c.ctx, c.key).Err(); err != nil {
+	return c.CloseContext(c.ctx)
+}
+
+// CloseContext 清理上下文
+func (c *monitorContext) CloseContext(ctx context.Context) (err error) {
+	if err = c.store.DelContext(ctx, c.key); err != nil {
 		return errors.Wrap(err, "[MonitorContext] Close Error")
 	}
+	c.mu.Lock()
 	c.closed = true
+	c.mu.Unlock()
 	return
 }
@@ -1,20 +1,27 @@
 package monitor
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/FredyXue/go-utils/retry"
 	"github.com/FredyXue/go-utils/testdata"
 	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/sync/errgroup"
 )
 
-var array []string
+var (
+	array   []string
+	arrayMu sync.Mutex
+)
 
 // 测试任务重入
 func TestMonitorReentry(t *testing.T) {
@@ -200,7 +207,9 @@ func (d *DoObj) String() string {
 
 func MethodDo(obj DoObj) {
 	log.Println("MethodDo", obj)
+	arrayMu.Lock()
 	array = append(array, obj.String())
+	arrayMu.Unlock()
 }
 
 func TestMonitorWatchTimeout(t *testing.T) {
@@ -265,3 +274,230 @@ func TestMonitorWatchTimeout(t *testing.T) {
 	err = m1.Unwatch("test_method_2", "2")
 	assert.NoError(t, err)
 }
+
+// 测试 ctx 传递
+func TestMonitorContextAPI(t *testing.T) {
+	redisClient := testdata.NewTestRedis()
+	group := "test_monitor_context"
+	cbCtx := make(chan context.Context, 1)
+
+	m1 := NewMonitor(redisClient,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+	)
+	m1.RegisterContext("test_method", func(ctx context.Context, mctx MonitorContext) {
+		body, err := mctx.GetContext(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, string(body), "m2_1")
+		cbCtx <- ctx
+		<-ctx.Done() // 阻塞直到 Stop
+	})
+	m1.Start(group)
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	// 已取消的 ctx，调用直接失败
+	canceled, cancel := context.WithCancel(context.TODO())
+	cancel()
+	_, err := m1.WatchContext(canceled, "test_method", "0")
+	assert.Equal(t, errors.Is(err, context.Canceled), true)
+	_, err = m1.WatchListContext(canceled)
+	assert.Equal(t, errors.Is(err, context.Canceled), true)
+
+	// m2 watch 后退出，由 m1 重入
+	m2 := NewMonitor(redisClient,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+	)
+	m2.RegisterContext("test_method", func(ctx context.Context, mctx MonitorContext) {})
+	m2.Start(group)
+	time.Sleep(time.Millisecond * 5)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+	_, err = m2.WatchContext(ctx, "test_method", "1", []byte("m2_1"))
+	assert.NoError(t, err)
+	m2.Stop()
+
+	var reentryCtx context.Context
+	select {
+	case reentryCtx = <-cbCtx:
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+	assert.NoError(t, reentryCtx.Err())
+
+	m1.Stop() // Stop 时取消重入 ctx
+	assert.Equal(t, errors.Is(reentryCtx.Err(), context.Canceled), true)
+}
+
+// failSetStore SetContext 总是失败
+type failSetStore struct {
+	Store
+}
+
+func (s failSetStore) SetContext(ctx context.Context, key string, body []byte, expiration time.Duration) error {
+	return errors.New("set failed")
+}
+
+// 测试 Watch 设置上下文失败时回滚任务列表
+func TestMonitorWatchRollback(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_watch_rollback"
+
+	m1 := NewMonitorWithStore(failSetStore{store},
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+	)
+	m1.Register("test_method", func(mctx MonitorContext) {})
+	m1.Start(group)
+	defer m1.Stop()
+
+	mctx, err := m1.Watch("test_method", "1", []byte("body"))
+	assert.Error(t, err)
+	assert.Nil(t, mctx)
+	list, err := m1.WatchList()
+	assert.Equal(t, []any{len(list), err}, []any{0, nil})
+	impl := m1.(*monitorImpl)
+	impl.mu.RLock()
+	assert.Equal(t, len(impl.localWatchMap), 0)
+	impl.mu.RUnlock()
+
+	// 不带上下文时不受影响
+	_, err = m1.Watch("test_method", "2")
+	assert.NoError(t, err)
+	list, _ = m1.WatchList()
+	assert.Equal(t, len(list), 1)
+}
+
+// 测试重新注册时未指定 CallOpt，清除之前的配置
+func TestMonitorRegisterOpt(t *testing.T) {
+	m1 := NewMonitorWithStore(NewMemoryStore()).(*monitorImpl)
+	m1.Register("test_method", func(mctx MonitorContext) {}, CallOpt{MaxAttempts: 3})
+	assert.Equal(t, m1.callOptMap["test_method"].MaxAttempts, int64(3))
+
+	m1.Register("test_method", func(mctx MonitorContext) {})
+	_, has := m1.callOptMap["test_method"]
+	assert.Equal(t, has, false)
+}
+
+// 测试重入失败重试
+func TestMonitorReentryRetry(t *testing.T) {
+	redisClient := testdata.NewTestRedis()
+	group := "test_monitor_retry"
+	var failCount, flakyCount int64
+
+	newMonitor := func() Monitor {
+		m := NewMonitor(redisClient,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Second),
+			WithWatchTimeout(time.Minute),
+		)
+		// 一直失败：每次重入本地重试 1 次，最多重入 2 次
+		m.RegisterWithError("test_method_fail", func(ctx context.Context, mctx MonitorContext) error {
+			atomic.AddInt64(&failCount, 1)
+			return errors.New("test error")
+		}, CallOpt{
+			Retry:       retry.NewRetry(retry.WithRetry(1), retry.WithInterval(time.Millisecond), retry.WithLogMode(false)),
+			MaxAttempts: 2,
+		})
+		// 首次失败，下一次重入成功
+		m.RegisterWithError("test_method_flaky", func(ctx context.Context, mctx MonitorContext) error {
+			if atomic.AddInt64(&flakyCount, 1) == 1 {
+				return errors.New("test error")
+			}
+			return nil
+		})
+		m.Start(group)
+		return m
+	}
+
+	m1 := newMonitor()
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	m2 := newMonitor()
+	time.Sleep(time.Millisecond * 5)
+	_, err := m2.Watch("test_method_fail", "1", nil)
+	assert.NoError(t, err)
+	_, err = m2.Watch("test_method_flaky", "1", nil)
+	assert.NoError(t, err)
+	m2.Stop() // 由 m1 重入
+
+	assert.Eventually(t, func() bool {
+		keys, err := m1.WatchList()
+		return err == nil && len(keys) == 0
+	}, time.Second, time.Millisecond*10)
+
+	assert.Equal(t, atomic.LoadInt64(&failCount), int64(4)) // 2 次重入 * (1 + 1 次重试)
+	assert.Equal(t, atomic.LoadInt64(&flakyCount), int64(2))
+
+	// 解除监控后，失败次数被清理
+	attempts, err := redisClient.HGetAll(context.TODO(), "{"+group+"}:Attempts").Result()
+	assert.NoError(t, err)
+	assert.Equal(t, len(attempts), 0)
+}
+
+// 测试重入失败后按 ReentryBackoff 退避，执行期间被移除的任务不会重新加入任务列表
+func TestMonitorReentryBackoff(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_reentry_backoff"
+	ctx := context.TODO()
+	var mu sync.Mutex
+	var callAt []time.Time
+	removed := make(chan struct{})
+	blocked := make(chan struct{}, 1)
+
+	m1 := NewMonitorWithStore(store,
+		WithHeartbeatTime(time.Millisecond*10),
+		WithHeartbeatTimeout(time.Second),
+		WithWatchTimeout(time.Minute),
+	)
+	m1.RegisterWithError("test_method", func(ctx context.Context, mctx MonitorContext) error {
+		mu.Lock()
+		callAt = append(callAt, time.Now())
+		mu.Unlock()
+		return errors.New("test error")
+	}, CallOpt{MaxAttempts: 3, ReentryBackoff: time.Millisecond * 50})
+	m1.RegisterWithError("test_method_removed", func(ctx context.Context, mctx MonitorContext) error {
+		blocked <- struct{}{}
+		<-removed
+		return errors.New("test error")
+	})
+	m1.Start(group)
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+
+	assert.NoError(t, store.Heartbeat(ctx, group, "ghost", time.Now().Add(-time.Minute).Unix()))
+	key := BuildKey(group, "test_method", "1")
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, nil, time.Minute))
+	removedKey := BuildKey(group, "test_method_removed", "1")
+	assert.NoError(t, store.AddWatch(ctx, group, removedKey, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, removedKey, nil, time.Minute))
+
+	// 执行期间被强制移除
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+	assert.NoError(t, NewAdmin(store, group).ForceUnwatch(ctx, removedKey))
+	close(removed)
+
+	// 3 次重入后移入死信列表
+	assert.Eventually(t, func() bool {
+		letters, err := store.DeadLetters(ctx, group)
+		return err == nil && len(letters) == 1
+	}, time.Second*2, time.Millisecond*10)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, len(callAt), 3)
+	assert.Equal(t, callAt[1].Sub(callAt[0]) >= time.Millisecond*50, true, callAt[1].Sub(callAt[0]))
+	assert.Equal(t, callAt[2].Sub(callAt[1]) >= time.Millisecond*100, true, callAt[2].Sub(callAt[1]))
+
+	maps, err := store.WatchList(ctx, group)
+	assert.Equal(t, []any{len(maps), err}, []any{0, nil})
+	assert.Equal(t, len(blocked), 0) // 不再重入
+}
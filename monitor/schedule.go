@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Schedule 延时任务，在 runAt 时由 master 加入任务列表并重入，执行注册的 callback
+// data 为 callback 中 mctx.Get 获取的上下文。任务与 Watch 共用 key，重入保证与 Watch 一致
+func (m *monitorImpl) Schedule(method string, tag string, runAt time.Time, data []byte) error {
+	return m.ScheduleContext(m.ctx, method, tag, runAt, data)
+}
+
+// ScheduleContext 延时任务，相同 method 与 tag 的延时任务会被覆盖
+func (m *monitorImpl) ScheduleContext(ctx context.Context, method string, tag string, runAt time.Time, data []byte) error {
+	m.mu.RLock()
+	_, has := m.callbackMap[method]
+	m.mu.RUnlock()
+	if !has {
+		return errors.Errorf("[Monitor] Schedule Error: method %s is unregistered", method)
+	}
+	err := m.store.AddSchedule(ctx, m.group, m.watchKey(method, tag), runAt, data)
+	return errors.Wrap(err, "[Monitor] Schedule AddSchedule Error")
+}
+
+// checkSchedules 只有 master 会执行该方法
+// 到期的延时任务写入上下文，并以无主任务加入任务列表，由 checkWatchList 重入
+func (m *monitorImpl) checkSchedules() {
+	schedules, err := m.store.DueSchedules(m.ctx, m.group, m.clock.Now())
+	if err != nil {
+		log.Println("[Monitor] checkSchedules DueSchedules Error:", err)
+		return
+	}
+	if len(schedules) == 0 {
+		return
+	}
+	maps, err := m.store.WatchList(m.ctx, m.group)
+	if err != nil {
+		log.Println("[Monitor] checkSchedules WatchList Error:", err)
+		return
+	}
+
+	for key, body := range schedules {
+		if _, has := maps[key]; has {
+			continue // 相同 key 的任务仍在执行，等待结束后再加入
+		}
+		if err = m.store.SetContext(m.ctx, key, body, m.watchTimeout); err != nil {
+			log.Printf("[Monitor] checkSchedules SetContext key: %s, Error: %v", key, err)
+			continue
+		}
+		if err = m.store.AddWatch(m.ctx, m.group, key, orphanUID, m.clock.Now().Unix()); err != nil {
+			log.Printf("[Monitor] checkSchedules AddWatch key: %s, Error: %v", key, err)
+			continue
+		}
+		// 移除失败时，任务结束后会再次执行，callback 需要幂等
+		if err = m.store.RemoveSchedule(m.ctx, m.group, key); err != nil {
+			log.Printf("[Monitor] checkSchedules RemoveSchedule key: %s, Error: %v", key, err)
+		}
+		m.emit(taskEvent(EventScheduleDue, key, orphanUID))
+	}
+}
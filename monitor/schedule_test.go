@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试延时任务到期后由 master 重入
+func TestMonitorSchedule(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_schedule"
+	ctx := context.TODO()
+	recorder := &eventRecorder{}
+	done := make(chan string, 10)
+
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Second),
+			WithWatchTimeout(time.Minute),
+			WithEventFunc(recorder.record),
+		)
+		m.Register("test_method", func(mctx MonitorContext) {
+			body, _ := mctx.Get()
+			done <- string(body)
+		})
+		m.Start(group)
+		return m
+	}
+	m1 := newMonitor()
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	m2 := newMonitor()
+	defer m2.Stop()
+
+	start := time.Now()
+	assert.NoError(t, m2.Schedule("test_method", "soon", start.Add(time.Millisecond*100), []byte("soon")))
+	assert.NoError(t, m2.Schedule("test_method", "later", start.Add(time.Hour), []byte("later")))
+	assert.Error(t, m2.Schedule("unknown_method", "tag", start, nil))
+
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "soon")
+		assert.Equal(t, time.Since(start) >= time.Millisecond*100, true)
+	case <-time.After(time.Second):
+		t.Fatal("schedule timeout")
+	}
+	e, has := recorder.find(EventScheduleDue)
+	assert.Equal(t, []any{has, e.Tag}, []any{true, "soon"})
+
+	// 执行完成后解除监控，未到期的任务保持不变
+	assert.Eventually(t, func() bool {
+		keys, _ := m1.WatchList()
+		return len(keys) == 0
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(t, len(done), 0)
+	due, _ := store.DueSchedules(ctx, group, start.Add(time.Hour))
+	assert.Equal(t, due, map[string][]byte{BuildKey(group, "test_method", "later"): []byte("later")})
+}
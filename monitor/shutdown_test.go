@@ -0,0 +1,105 @@
+package monitor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试优雅退出：等待重入任务结束，本地任务立即交给集群
+func TestMonitorShutdown(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_shutdown"
+	ctx := context.TODO()
+	started := make(chan string, 10)
+	done := make(chan string, 10)
+	release := make(chan struct{})
+	var finished int32
+
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Minute), // 不依赖心跳超时
+			WithWatchTimeout(time.Minute),
+		)
+		m.Register("test_method", func(mctx MonitorContext) {
+			body, _ := mctx.Get()
+			started <- string(body)
+			switch string(body) {
+			case "slow":
+				time.Sleep(time.Millisecond * 50)
+				atomic.StoreInt32(&finished, 1)
+			case "block":
+				<-release
+			}
+			done <- string(body)
+		})
+		m.Start(group)
+		return m
+	}
+
+	m1 := newMonitor()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	m2 := newMonitor()
+	m3 := newMonitor()
+	defer m3.Stop()
+	time.Sleep(time.Millisecond * 20)
+
+	// m1 正在重入的任务
+	key := group + "|test_method|slow"
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("slow"), time.Minute))
+	assert.Equal(t, <-started, "slow")
+
+	// m2 本地任务，退出后立即交给集群
+	_, err := m2.Watch("test_method", "local", []byte("local"))
+	assert.NoError(t, err)
+	// 已被 m3 接管的本地任务，退出时不交回集群
+	_, err = m2.Watch("test_method", "taken", []byte("taken"))
+	assert.NoError(t, err)
+	takenKey := group + "|test_method|taken"
+	uid3 := m3.(*monitorImpl).uid
+	assert.NoError(t, store.AddWatch(ctx, group, takenKey, uid3, time.Now().Unix()))
+	assert.NoError(t, m2.Shutdown(ctx))
+	maps, _ := store.WatchList(ctx, group)
+	assert.Equal(t, maps[takenKey], uid3)
+	assert.NoError(t, store.RemoveWatch(ctx, group, takenKey))
+	_, err = m2.Watch("test_method", "local2", nil)
+	assert.ErrorIs(t, err, ErrShuttingDown)
+
+	// m1 等待重入任务结束后退出
+	assert.NoError(t, m1.Shutdown(ctx))
+	assert.Equal(t, atomic.LoadInt32(&finished), int32(1))
+
+	// m2 交出的任务由 master 重入，m3 成为新的 master
+	finishedBodies := map[string]bool{}
+	for len(finishedBodies) < 2 {
+		select {
+		case body := <-done:
+			finishedBodies[body] = true
+		case <-time.After(time.Second):
+			t.Fatal("hand off timeout")
+		}
+	}
+	assert.Equal(t, finishedBodies, map[string]bool{"slow": true, "local": true})
+	assert.Eventually(t, m3.IsMaster, time.Second, time.Millisecond*10)
+
+	// 等待超时
+	key = group + "|test_method|block"
+	assert.NoError(t, store.AddWatch(ctx, group, key, "ghost", time.Now().Unix()))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("block"), time.Minute))
+	for body := range started {
+		if body == "block" {
+			break
+		}
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, time.Millisecond*20)
+	defer cancel()
+	assert.ErrorIs(t, m3.Shutdown(timeoutCtx), context.DeadlineExceeded)
+	close(release)
+	maps, _ = store.WatchList(ctx, group)
+	assert.Equal(t, maps, map[string]string{key: orphanUID}) // 未完成的重入任务交给集群
+}
@@ -0,0 +1,547 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/FredyXue/go-utils"
+	"github.com/FredyXue/go-utils/monitor/locker"
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+// ErrNil 上下文不存在
+// 与 redis.Nil 保持一致，方便业务层统一判断
+var ErrNil = redis.Nil
+
+// Store monitor 存储后端
+// 覆盖节点心跳、任务列表、上下文数据三类存储，以及选举使用的 Locker
+type Store interface {
+	Heartbeat(ctx context.Context, group string, uid string, timestamp int64) error // 更新节点心跳
+	NodeList(ctx context.Context, group string) (map[string]int64, error)           // 节点列表 uid -> timestamp
+	RemoveNode(ctx context.Context, group string, uid string) error                 // 从节点列表移除
+
+	AddWatch(ctx context.Context, group string, key string, uid string, timestamp int64) error // 加入任务列表，timestamp 为首次加入的时间，重入时不更新
+	RemoveWatch(ctx context.Context, group string, key string) error                           // 从任务列表移除
+	RemoveWatchIf(ctx context.Context, group string, key string, uid string) (bool, error)     // 任务仍属于 uid 时从任务列表移除，否则返回 false
+	WatchList(ctx context.Context, group string) (map[string]string, error)                    // 任务列表 key -> uid
+	WatchTimes(ctx context.Context, group string) (map[string]int64, error)                    // 任务首次加入任务列表的时间 key -> timestamp
+
+	IncrAttempts(ctx context.Context, group string, key string) (int64, error) // 重入次数 +1，RemoveWatch 时清理
+
+	ClaimWatch(ctx context.Context, group string, key string, from string, to string) (bool, error)          // 任务属于 from 或 to 时改为属于 to，并清除重入延迟。任务已移除或属于其他节点时返回 false
+	ReleaseWatch(ctx context.Context, group string, key string, uid string, retryAt time.Time) (bool, error) // 任务仍属于 uid 时标记为无主任务，retryAt 之前不重入。任务已移除或属于其他节点时返回 false
+	DelayedWatches(ctx context.Context, group string, now time.Time) ([]string, error)                       // 尚未到下一次重入时间的任务，AddWatch、RemoveWatch 时清理
+
+	AddDeadLetter(ctx context.Context, group string, key string, body []byte) error // 从任务列表移除并加入死信列表
+	DeadLetters(ctx context.Context, group string) (map[string][]byte, error)       // 死信列表 key -> body
+	RemoveDeadLetter(ctx context.Context, group string, key string) error           // 从死信列表移除
+
+	AddSchedule(ctx context.Context, group string, key string, runAt time.Time, body []byte) error // 加入延时任务，相同 key 覆盖
+	DueSchedules(ctx context.Context, group string, now time.Time) (map[string][]byte, error)      // 已到期的延时任务 key -> body
+	RemoveSchedule(ctx context.Context, group string, key string) error                            // 移除延时任务
+
+	CronTimes(ctx context.Context, group string) (map[string]int64, error)             // 定时任务最近一次执行的计划时间 name -> ms
+	SetCronTime(ctx context.Context, group string, name string, runAt time.Time) error // 记录定时任务的执行时间，只在晚于已记录的时间时更新
+
+	PublishCancel(ctx context.Context, group string, key string) error                               // 广播取消任务，不保证送达
+	SubscribeCancel(ctx context.Context, group string, fn func(key string)) (<-chan struct{}, error) // 订阅取消任务，订阅成功后返回。ctx 结束时取消订阅，订阅结束时关闭返回的 channel
+
+	SetLease(ctx context.Context, group string, key string, expireAt time.Time) error // 更新任务租约，RemoveWatch 时清理
+	ExpiredLeases(ctx context.Context, group string, now time.Time) ([]string, error) // 租约已过期的任务
+
+	Assign(ctx context.Context, group string, key string, from string, to string) (bool, error) // 任务仍属于 from 时分配给节点 to。任务已移除或属于其他节点时返回 false
+	Assignments(ctx context.Context, group string, uid string) (map[string]string, error)       // 领取分配给节点的任务 key -> from，RemoveNode 时清理
+
+	GetContext(ctx context.Context, key string) ([]byte, error)                              // 获取上下文，不存在返回 ErrNil
+	SetContext(ctx context.Context, key string, body []byte, expiration time.Duration) error // 设置上下文
+	ContextTTL(ctx context.Context, key string) (time.Duration, error)                       // 上下文剩余时长。-1 永不过期，-2 不存在
+	DelContext(ctx context.Context, key string) error                                        // 删除上下文，同时删除步骤
+
+	SetStep(ctx context.Context, key string, step string, body []byte, expiration time.Duration) error // 记录步骤，同时刷新上下文时长
+	GetSteps(ctx context.Context, key string) (map[string][]byte, error)                               // 步骤列表 step -> body
+
+	SetMaster(ctx context.Context, group string, uid string, expiration time.Duration) error // 记录当前 master
+	GetMaster(ctx context.Context, group string) (string, error)                             // 当前 master，不存在返回 ErrNil
+
+	NewLocker(opts ...locker.Option) locker.Locker // 选举使用的 Locker
+}
+
+// redisStore 基于 redis 的存储，支持 redis cluster
+// 所有 key 使用 hash tag {group}，同一个 group 位于同一个 slot，事务与多 key 操作不会跨 slot
+// 节点列表 {group}:List  ZSET  uid -> timestamp
+// 任务列表 {group}:WatchList  HASH  key -> uid
+// 任务时间 {group}:WatchTime  HASH  key -> timestamp
+// 重入次数 {group}:Attempts  HASH  key -> count
+// 死信列表 {group}:DeadLetter  HASH  key -> body
+// 任务租约 {group}:Lease  ZSET  key -> 过期时间 ms
+// 重入延迟 {group}:RetryAt  ZSET  key -> 下一次重入时间 ms
+// 延时任务 {group}:Schedule  ZSET  key -> 执行时间 ms
+// 延时数据 {group}:ScheduleData  HASH  key -> body
+// 定时任务 {group}:Cron  HASH  name -> 最近一次执行的计划时间 ms
+// 取消任务 {group}:Cancel  PUB/SUB 频道  key
+// 分配任务 {group}:Assign:uid  HASH  key -> from
+// master   {group}:Master  STR  uid
+// 上下文   {group}|method|tag  STR
+// 步骤     {group}|method|tag:Steps  HASH  step -> body，与上下文的时长一致
+type redisStore struct {
+	cli redis.UniversalClient
+}
+
+// NewRedisStore 支持 *redis.Client、*redis.ClusterClient 等 redis.UniversalClient
+func NewRedisStore(cli redis.UniversalClient) Store {
+	return &redisStore{cli: cli}
+}
+
+// groupTag hash tag，同一个 group 的 key 位于同一个 slot
+func (s *redisStore) groupTag(group string) string {
+	return "{" + group + "}"
+}
+
+// contextKey 上下文的 redis key，group|method|tag -> {group}|method|tag
+func (s *redisStore) contextKey(key string) string {
+	if i := keyGroupEnd(key); i > 0 {
+		return s.groupTag(key[:i]) + key[i:]
+	}
+	return key
+}
+
+func (s *redisStore) groupList(group string) string {
+	return s.groupTag(group) + ":List"
+}
+
+func (s *redisStore) groupWatchList(group string) string {
+	return s.groupTag(group) + ":WatchList"
+}
+
+func (s *redisStore) groupWatchTime(group string) string {
+	return s.groupTag(group) + ":WatchTime"
+}
+
+func (s *redisStore) groupMaster(group string) string {
+	return s.groupTag(group) + ":Master"
+}
+
+func (s *redisStore) groupLease(group string) string {
+	return s.groupTag(group) + ":Lease"
+}
+
+func (s *redisStore) groupRetryAt(group string) string {
+	return s.groupTag(group) + ":RetryAt"
+}
+
+func (s *redisStore) groupAttempts(group string) string {
+	return s.groupTag(group) + ":Attempts"
+}
+
+func (s *redisStore) groupSchedule(group string) string {
+	return s.groupTag(group) + ":Schedule"
+}
+
+func (s *redisStore) groupScheduleData(group string) string {
+	return s.groupTag(group) + ":ScheduleData"
+}
+
+func (s *redisStore) groupCron(group string) string {
+	return s.groupTag(group) + ":Cron"
+}
+
+func (s *redisStore) groupDeadLetter(group string) string {
+	return s.groupTag(group) + ":DeadLetter"
+}
+
+func (s *redisStore) groupCancel(group string) string {
+	return s.groupTag(group) + ":Cancel"
+}
+
+func (s *redisStore) contextSteps(key string) string {
+	return s.contextKey(key) + ":Steps"
+}
+
+func (s *redisStore) groupAssign(group string, uid string) string {
+	return s.groupTag(group) + ":Assign:" + uid
+}
+
+func (s *redisStore) Heartbeat(ctx context.Context, group string, uid string, timestamp int64) error {
+	z := &redis.Z{
+		Score:  float64(timestamp),
+		Member: uid,
+	}
+	err := s.cli.ZAdd(ctx, s.groupList(group), z).Err()
+	return errors.Wrap(err, "[RedisStore] Heartbeat ZAdd Error")
+}
+
+func (s *redisStore) NodeList(ctx context.Context, group string) (nodes map[string]int64, err error) {
+	listZ, err := s.cli.ZRangeWithScores(ctx, s.groupList(group), 0, -1).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] NodeList ZRangeWithScores Error")
+		return
+	}
+	nodes = make(map[string]int64, len(listZ))
+	for _, z := range listZ {
+		nodes[z.Member.(string)] = int64(z.Score)
+	}
+	return
+}
+
+func (s *redisStore) RemoveNode(ctx context.Context, group string, uid string) error {
+	pipe := s.cli.TxPipeline()
+	pipe.ZRem(ctx, s.groupList(group), uid)
+	pipe.Del(ctx, s.groupAssign(group, uid))
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] RemoveNode ZRem Error")
+}
+
+func (s *redisStore) AddWatch(ctx context.Context, group string, key string, uid string, timestamp int64) error {
+	pipe := s.cli.TxPipeline()
+	pipe.HSet(ctx, s.groupWatchList(group), key, uid)
+	pipe.HSetNX(ctx, s.groupWatchTime(group), key, timestamp) // 重入时不更新
+	pipe.ZRem(ctx, s.groupRetryAt(group), key)
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] AddWatch HSet Error")
+}
+
+func (s *redisStore) RemoveWatch(ctx context.Context, group string, key string) error {
+	pipe := s.cli.TxPipeline()
+	pipe.HDel(ctx, s.groupWatchList(group), key)
+	pipe.HDel(ctx, s.groupWatchTime(group), key)
+	pipe.HDel(ctx, s.groupAttempts(group), key)
+	pipe.ZRem(ctx, s.groupLease(group), key)
+	pipe.ZRem(ctx, s.groupRetryAt(group), key)
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] RemoveWatch HDel Error")
+}
+
+func (s *redisStore) RemoveWatchIf(ctx context.Context, group string, key string, uid string) (bool, error) {
+	lua := `
+	if redis.call("hget", KEYS[1], ARGV[1]) ~= ARGV[2] then
+		return 0
+	end
+	redis.call("hdel", KEYS[1], ARGV[1])
+	redis.call("hdel", KEYS[2], ARGV[1])
+	redis.call("hdel", KEYS[3], ARGV[1])
+	redis.call("zrem", KEYS[4], ARGV[1])
+	redis.call("zrem", KEYS[5], ARGV[1])
+	return 1
+	`
+	keys := []string{s.groupWatchList(group), s.groupWatchTime(group), s.groupAttempts(group), s.groupLease(group), s.groupRetryAt(group)}
+	rlt, err := s.cli.Eval(ctx, lua, keys, key, uid).Int()
+	return rlt == 1, errors.Wrap(err, "[RedisStore] RemoveWatchIf Eval Error")
+}
+
+func (s *redisStore) WatchList(ctx context.Context, group string) (maps map[string]string, err error) {
+	maps, err = s.cli.HGetAll(ctx, s.groupWatchList(group)).Result()
+	err = errors.Wrap(err, "[RedisStore] WatchList HGetAll Error")
+	return
+}
+
+func (s *redisStore) WatchTimes(ctx context.Context, group string) (times map[string]int64, err error) {
+	maps, err := s.cli.HGetAll(ctx, s.groupWatchTime(group)).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] WatchTimes HGetAll Error")
+		return
+	}
+	times = make(map[string]int64, len(maps))
+	for key, val := range maps {
+		times[key], _ = strconv.ParseInt(val, 10, 64)
+	}
+	return
+}
+
+func (s *redisStore) IncrAttempts(ctx context.Context, group string, key string) (count int64, err error) {
+	count, err = s.cli.HIncrBy(ctx, s.groupAttempts(group), key, 1).Result()
+	err = errors.Wrap(err, "[RedisStore] IncrAttempts HIncrBy Error")
+	return
+}
+
+func (s *redisStore) ClaimWatch(ctx context.Context, group string, key string, from string, to string) (bool, error) {
+	lua := `
+	local owner = redis.call("hget", KEYS[1], ARGV[1])
+	if owner ~= ARGV[2] and owner ~= ARGV[3] then
+		return 0
+	end
+	redis.call("hset", KEYS[1], ARGV[1], ARGV[3])
+	redis.call("zrem", KEYS[2], ARGV[1])
+	return 1
+	`
+	rlt, err := s.cli.Eval(ctx, lua, []string{s.groupWatchList(group), s.groupRetryAt(group)}, key, from, to).Int()
+	return rlt == 1, errors.Wrap(err, "[RedisStore] ClaimWatch Eval Error")
+}
+
+func (s *redisStore) ReleaseWatch(ctx context.Context, group string, key string, uid string, retryAt time.Time) (bool, error) {
+	lua := `
+	if redis.call("hget", KEYS[1], ARGV[1]) ~= ARGV[2] then
+		return 0
+	end
+	redis.call("hset", KEYS[1], ARGV[1], ARGV[3])
+	redis.call("zadd", KEYS[2], ARGV[4], ARGV[1])
+	return 1
+	`
+	keys := []string{s.groupWatchList(group), s.groupRetryAt(group)}
+	rlt, err := s.cli.Eval(ctx, lua, keys, key, uid, orphanUID, retryAt.UnixMilli()).Int()
+	return rlt == 1, errors.Wrap(err, "[RedisStore] ReleaseWatch Eval Error")
+}
+
+func (s *redisStore) DelayedWatches(ctx context.Context, group string, now time.Time) (keys []string, err error) {
+	keys, err = s.cli.ZRangeByScore(ctx, s.groupRetryAt(group), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(now.UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	err = errors.Wrap(err, "[RedisStore] DelayedWatches ZRangeByScore Error")
+	return
+}
+
+func (s *redisStore) AddDeadLetter(ctx context.Context, group string, key string, body []byte) error {
+	pipe := s.cli.TxPipeline()
+	pipe.HDel(ctx, s.groupWatchList(group), key)
+	pipe.HDel(ctx, s.groupWatchTime(group), key)
+	pipe.HDel(ctx, s.groupAttempts(group), key)
+	pipe.ZRem(ctx, s.groupLease(group), key)
+	pipe.ZRem(ctx, s.groupRetryAt(group), key)
+	pipe.HSet(ctx, s.groupDeadLetter(group), key, body)
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] AddDeadLetter Error")
+}
+
+func (s *redisStore) DeadLetters(ctx context.Context, group string) (maps map[string][]byte, err error) {
+	rlt, err := s.cli.HGetAll(ctx, s.groupDeadLetter(group)).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] DeadLetters HGetAll Error")
+		return
+	}
+	maps = make(map[string][]byte, len(rlt))
+	for key, body := range rlt {
+		maps[key] = []byte(body)
+	}
+	return
+}
+
+func (s *redisStore) RemoveDeadLetter(ctx context.Context, group string, key string) error {
+	err := s.cli.HDel(ctx, s.groupDeadLetter(group), key).Err()
+	return errors.Wrap(err, "[RedisStore] RemoveDeadLetter HDel Error")
+}
+
+func (s *redisStore) AddSchedule(ctx context.Context, group string, key string, runAt time.Time, body []byte) error {
+	pipe := s.cli.TxPipeline()
+	pipe.HSet(ctx, s.groupScheduleData(group), key, body)
+	pipe.ZAdd(ctx, s.groupSchedule(group), &redis.Z{
+		Score:  float64(runAt.UnixMilli()),
+		Member: key,
+	})
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] AddSchedule Error")
+}
+
+func (s *redisStore) DueSchedules(ctx context.Context, group string, now time.Time) (maps map[string][]byte, err error) {
+	keys, err := s.cli.ZRangeByScore(ctx, s.groupSchedule(group), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] DueSchedules ZRangeByScore Error")
+		return
+	}
+	maps = make(map[string][]byte, len(keys))
+	if len(keys) == 0 {
+		return
+	}
+	values, err := s.cli.HMGet(ctx, s.groupScheduleData(group), keys...).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] DueSchedules HMGet Error")
+		return
+	}
+	for i, key := range keys {
+		body, _ := values[i].(string) // 数据缺失时为空
+		maps[key] = []byte(body)
+	}
+	return
+}
+
+func (s *redisStore) RemoveSchedule(ctx context.Context, group string, key string) error {
+	pipe := s.cli.TxPipeline()
+	pipe.ZRem(ctx, s.groupSchedule(group), key)
+	pipe.HDel(ctx, s.groupScheduleData(group), key)
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] RemoveSchedule Error")
+}
+
+func (s *redisStore) CronTimes(ctx context.Context, group string) (times map[string]int64, err error) {
+	maps, err := s.cli.HGetAll(ctx, s.groupCron(group)).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] CronTimes HGetAll Error")
+		return
+	}
+	times = make(map[string]int64, len(maps))
+	for name, v := range maps {
+		times[name], _ = strconv.ParseInt(v, 10, 64)
+	}
+	return
+}
+
+func (s *redisStore) SetCronTime(ctx context.Context, group string, name string, runAt time.Time) error {
+	lua := `
+	local last = redis.call("hget", KEYS[1], ARGV[1])
+	if last and tonumber(last) >= tonumber(ARGV[2]) then
+		return 0
+	end
+	redis.call("hset", KEYS[1], ARGV[1], ARGV[2])
+	return 1
+	`
+	err := s.cli.Eval(ctx, lua, []string{s.groupCron(group)}, name, runAt.UnixMilli()).Err()
+	return errors.Wrap(err, "[RedisStore] SetCronTime Eval Error")
+}
+
+func (s *redisStore) PublishCancel(ctx context.Context, group string, key string) error {
+	err := s.cli.Publish(ctx, s.groupCancel(group), key).Err()
+	return errors.Wrap(err, "[RedisStore] PublishCancel Error")
+}
+
+func (s *redisStore) SubscribeCancel(ctx context.Context, group string, fn func(key string)) (<-chan struct{}, error) {
+	pubsub := s.cli.Subscribe(ctx, s.groupCancel(group))
+	// 等待订阅确认，之后发布的消息不会丢失
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, errors.Wrap(err, "[RedisStore] SubscribeCancel Error")
+	}
+	ch := pubsub.Channel()
+	done := make(chan struct{})
+	go utils.Protect(func() {
+		defer close(done)
+		defer pubsub.Close()
+		for {
+			select {
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				fn(msg.Payload)
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+	return done, nil
+}
+
+func (s *redisStore) SetLease(ctx context.Context, group string, key string, expireAt time.Time) error {
+	z := &redis.Z{
+		Score:  float64(expireAt.UnixMilli()),
+		Member: key,
+	}
+	err := s.cli.ZAdd(ctx, s.groupLease(group), z).Err()
+	return errors.Wrap(err, "[RedisStore] SetLease ZAdd Error")
+}
+
+func (s *redisStore) ExpiredLeases(ctx context.Context, group string, now time.Time) (keys []string, err error) {
+	keys, err = s.cli.ZRangeByScore(ctx, s.groupLease(group), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	err = errors.Wrap(err, "[RedisStore] ExpiredLeases ZRangeByScore Error")
+	return
+}
+
+func (s *redisStore) Assign(ctx context.Context, group string, key string, from string, to string) (bool, error) {
+	lua := `
+	if redis.call("hget", KEYS[1], ARGV[1]) ~= ARGV[2] then
+		return 0
+	end
+	redis.call("hset", KEYS[1], ARGV[1], ARGV[3])
+	redis.call("hset", KEYS[2], ARGV[1], ARGV[2])
+	return 1
+	`
+	keys := []string{s.groupWatchList(group), s.groupAssign(group, to)}
+	rlt, err := s.cli.Eval(ctx, lua, keys, key, from, to).Int()
+	return rlt == 1, errors.Wrap(err, "[RedisStore] Assign Eval Error")
+}
+
+func (s *redisStore) Assignments(ctx context.Context, group string, uid string) (maps map[string]string, err error) {
+	pipe := s.cli.TxPipeline()
+	getCmd := pipe.HGetAll(ctx, s.groupAssign(group, uid))
+	pipe.Del(ctx, s.groupAssign(group, uid))
+	if _, err = pipe.Exec(ctx); err != nil {
+		err = errors.Wrap(err, "[RedisStore] Assignments HGetAll Error")
+		return
+	}
+	maps = getCmd.Val()
+	return
+}
+
+func (s *redisStore) GetContext(ctx context.Context, key string) (body []byte, err error) {
+	rlt, err := s.cli.Get(ctx, s.contextKey(key)).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] GetContext Error")
+		return
+	}
+	body = []byte(rlt)
+	return
+}
+
+func (s *redisStore) SetContext(ctx context.Context, key string, body []byte, expiration time.Duration) error {
+	pipe := s.cli.TxPipeline()
+	pipe.Set(ctx, s.contextKey(key), body, expiration)
+	s.expire(ctx, pipe, s.contextSteps(key), expiration)
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] SetContext Error")
+}
+
+func (s *redisStore) ContextTTL(ctx context.Context, key string) (ttl time.Duration, err error) {
+	ttl, err = s.cli.TTL(ctx, s.contextKey(key)).Result()
+	err = errors.Wrap(err, "[RedisStore] ContextTTL Error")
+	return
+}
+
+func (s *redisStore) DelContext(ctx context.Context, key string) error {
+	err := s.cli.Del(ctx, s.contextKey(key), s.contextSteps(key)).Err()
+	return errors.Wrap(err, "[RedisStore] DelContext Error")
+}
+
+func (s *redisStore) SetStep(ctx context.Context, key string, step string, body []byte, expiration time.Duration) error {
+	pipe := s.cli.TxPipeline()
+	pipe.HSet(ctx, s.contextSteps(key), step, body)
+	s.expire(ctx, pipe, s.contextSteps(key), expiration)
+	s.expire(ctx, pipe, s.contextKey(key), expiration)
+	_, err := pipe.Exec(ctx)
+	return errors.Wrap(err, "[RedisStore] SetStep Error")
+}
+
+func (s *redisStore) GetSteps(ctx context.Context, key string) (maps map[string][]byte, err error) {
+	rlt, err := s.cli.HGetAll(ctx, s.contextSteps(key)).Result()
+	if err != nil {
+		err = errors.Wrap(err, "[RedisStore] GetSteps Error")
+		return
+	}
+	maps = make(map[string][]byte, len(rlt))
+	for step, body := range rlt {
+		maps[step] = []byte(body)
+	}
+	return
+}
+
+// expire 设置时长，expiration <= 0 表示永不过期
+func (s *redisStore) expire(ctx context.Context, pipe redis.Pipeliner, key string, expiration time.Duration) {
+	if expiration > 0 {
+		pipe.PExpire(ctx, key, expiration)
+	} else {
+		pipe.Persist(ctx, key)
+	}
+}
+
+func (s *redisStore) SetMaster(ctx context.Context, group string, uid string, expiration time.Duration) error {
+	err := s.cli.Set(ctx, s.groupMaster(group), uid, expiration).Err()
+	return errors.Wrap(err, "[RedisStore] SetMaster Set Error")
+}
+
+func (s *redisStore) GetMaster(ctx context.Context, group string) (uid string, err error) {
+	uid, err = s.cli.Get(ctx, s.groupMaster(group)).Result()
+	if errors.Is(err, redis.Nil) {
+		return // 保持 ErrNil
+	}
+	err = errors.Wrap(err, "[RedisStore] GetMaster Get Error")
+	return
+}
+
+func (s *redisStore) NewLocker(opts ...locker.Option) locker.Locker {
+	return locker.NewRedisLocker(s.cli, opts...)
+}
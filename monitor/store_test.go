@@ -0,0 +1,308 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/FredyXue/go-utils/testdata"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+)
+
+// 测试 redis 与 memory 两种存储的行为一致
+func TestStore(t *testing.T) {
+	stores := map[string]Store{
+		"redis":  NewRedisStore(testdata.NewTestRedis()),
+		"memory": NewMemoryStore(),
+	}
+	for name, store := range stores {
+		ctx := context.TODO()
+		group := "test_store_" + name
+
+		// 节点列表
+		assert.NoError(t, store.Heartbeat(ctx, group, "uid1", 100))
+		assert.NoError(t, store.Heartbeat(ctx, group, "uid2", 200))
+		assert.NoError(t, store.Heartbeat(ctx, group, "uid1", 300))
+		nodes, err := store.NodeList(ctx, group)
+		assert.NoError(t, err)
+		assert.Equal(t, nodes, map[string]int64{"uid1": 300, "uid2": 200}, name)
+		assert.NoError(t, store.RemoveNode(ctx, group, "uid2"))
+		nodes, _ = store.NodeList(ctx, group)
+		assert.Equal(t, nodes, map[string]int64{"uid1": 300}, name)
+
+		// 任务列表
+		assert.NoError(t, store.AddWatch(ctx, group, "key1", "uid1", time.Now().Unix()))
+		assert.NoError(t, store.AddWatch(ctx, group, "key2", "uid2", time.Now().Unix()))
+		assert.NoError(t, store.RemoveWatch(ctx, group, "key2"))
+		maps, err := store.WatchList(ctx, group)
+		assert.NoError(t, err)
+		assert.Equal(t, maps, map[string]string{"key1": "uid1"}, name)
+		// 租约
+		now := time.Now()
+		assert.NoError(t, store.SetLease(ctx, group, "key1", now.Add(time.Minute)))
+		assert.NoError(t, store.SetLease(ctx, group, "key2", now.Add(-time.Second)))
+		expired, err := store.ExpiredLeases(ctx, group, now)
+		assert.NoError(t, err)
+		assert.Equal(t, expired, []string{"key2"}, name)
+		assert.NoError(t, store.RemoveWatch(ctx, group, "key2"))
+		expired, _ = store.ExpiredLeases(ctx, group, now.Add(time.Hour))
+		assert.Equal(t, expired, []string{"key1"}, name)
+
+		times, err := store.WatchTimes(ctx, group)
+		assert.NoError(t, err)
+		assert.Equal(t, []any{len(times), times["key1"] > 0}, []any{1, true}, name)
+
+		// 任务分配
+		assigned, err := store.Assign(ctx, group, "key1", "uid2", "uid3")
+		assert.Equal(t, []any{assigned, err}, []any{false, nil}, name) // 不属于 uid2
+		assigned, err = store.Assign(ctx, group, "key1", "uid1", "uid3")
+		assert.Equal(t, []any{assigned, err}, []any{true, nil}, name)
+		maps, _ = store.WatchList(ctx, group)
+		assert.Equal(t, maps, map[string]string{"key1": "uid3"}, name)
+		assigns, err := store.Assignments(ctx, group, "uid3")
+		assert.NoError(t, err)
+		assert.Equal(t, assigns, map[string]string{"key1": "uid1"}, name)
+		assigns, _ = store.Assignments(ctx, group, "uid3")
+		assert.Equal(t, len(assigns), 0, name)
+
+		// 认领与释放
+		claimed, err := store.ClaimWatch(ctx, group, "key1", "uid1", "uid4")
+		assert.Equal(t, []any{claimed, err}, []any{false, nil}, name) // 已属于 uid3
+		claimed, err = store.ClaimWatch(ctx, group, "key1", "uid3", "uid4")
+		assert.Equal(t, []any{claimed, err}, []any{true, nil}, name)
+		claimed, _ = store.ClaimWatch(ctx, group, "key1", "uid3", "uid4") // 重复认领
+		assert.Equal(t, claimed, true, name)
+		released, err := store.ReleaseWatch(ctx, group, "key1", "uid3", now.Add(time.Minute))
+		assert.Equal(t, []any{released, err}, []any{false, nil}, name)
+		released, err = store.ReleaseWatch(ctx, group, "key1", "uid4", now.Add(time.Minute))
+		assert.Equal(t, []any{released, err}, []any{true, nil}, name)
+		maps, _ = store.WatchList(ctx, group)
+		assert.Equal(t, maps, map[string]string{"key1": orphanUID}, name)
+		delayed, err := store.DelayedWatches(ctx, group, now)
+		assert.Equal(t, []any{delayed, err}, []any{[]string{"key1"}, nil}, name)
+		delayed, _ = store.DelayedWatches(ctx, group, now.Add(time.Hour))
+		assert.Equal(t, len(delayed), 0, name)
+		released, _ = store.ReleaseWatch(ctx, group, "key9", "uid4", now) // 任务不存在
+		assert.Equal(t, released, false, name)
+		claimed, _ = store.ClaimWatch(ctx, group, "key1", orphanUID, "uid3")
+		assert.Equal(t, claimed, true, name)
+		delayed, _ = store.DelayedWatches(ctx, group, now)
+		assert.Equal(t, len(delayed), 0, name)
+		assert.NoError(t, store.AddWatch(ctx, group, "key6", "uid1", time.Now().Unix()))
+		removed, err := store.RemoveWatchIf(ctx, group, "key6", "uid2")
+		assert.Equal(t, []any{removed, err}, []any{false, nil}, name)
+		removed, err = store.RemoveWatchIf(ctx, group, "key6", "uid1")
+		assert.Equal(t, []any{removed, err}, []any{true, nil}, name)
+		maps, _ = store.WatchList(ctx, group)
+		assert.Equal(t, maps, map[string]string{"key1": "uid3"}, name)
+
+		// 延时任务
+		assert.NoError(t, store.AddSchedule(ctx, group, "key4", now.Add(-time.Second), []byte("data4")))
+		assert.NoError(t, store.AddSchedule(ctx, group, "key5", now.Add(time.Minute), []byte("data5")))
+		schedules, err := store.DueSchedules(ctx, group, now)
+		assert.NoError(t, err)
+		assert.Equal(t, schedules, map[string][]byte{"key4": []byte("data4")}, name)
+		assert.NoError(t, store.RemoveSchedule(ctx, group, "key4"))
+		schedules, _ = store.DueSchedules(ctx, group, now.Add(time.Hour))
+		assert.Equal(t, schedules, map[string][]byte{"key5": []byte("data5")}, name)
+
+		// 定时任务
+		assert.NoError(t, store.SetCronTime(ctx, group, "cron1", now))
+		crons, err := store.CronTimes(ctx, group)
+		assert.NoError(t, err)
+		assert.Equal(t, crons, map[string]int64{"cron1": now.UnixMilli()}, name)
+		assert.NoError(t, store.SetCronTime(ctx, group, "cron1", now.Add(-time.Minute))) // 不会回退
+		crons, _ = store.CronTimes(ctx, group)
+		assert.Equal(t, crons, map[string]int64{"cron1": now.UnixMilli()}, name)
+
+		// 取消任务，miniredis v2.5 不支持 pub/sub
+		if name == "memory" {
+			subCtx, subCancel := context.WithCancel(ctx)
+			received := make(chan string, 1)
+			done, err := store.SubscribeCancel(subCtx, group, func(key string) { received <- key })
+			assert.NoError(t, err)
+			assert.NoError(t, store.PublishCancel(ctx, group, "key6"))
+			select {
+			case key := <-received:
+				assert.Equal(t, key, "key6", name)
+			case <-time.After(time.Second):
+				t.Fatal("subscribe timeout", name)
+			}
+			subCancel()
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("unsubscribe timeout", name)
+			}
+		}
+
+		// 死信列表
+		assert.NoError(t, store.AddWatch(ctx, group, "key3", "uid1", time.Now().Unix()))
+		attempts, err := store.IncrAttempts(ctx, group, "key3")
+		assert.Equal(t, []any{attempts, err}, []any{int64(1), nil}, name)
+		assert.NoError(t, store.AddDeadLetter(ctx, group, "key3", []byte("letter")))
+		maps, _ = store.WatchList(ctx, group)
+		assert.Equal(t, maps, map[string]string{"key1": "uid3"}, name)
+		letters, err := store.DeadLetters(ctx, group)
+		assert.NoError(t, err)
+		assert.Equal(t, letters, map[string][]byte{"key3": []byte("letter")}, name)
+		attempts, _ = store.IncrAttempts(ctx, group, "key3")
+		assert.Equal(t, attempts, int64(1), name) // 移入死信列表时清理重入次数
+		assert.NoError(t, store.RemoveDeadLetter(ctx, group, "key3"))
+		letters, _ = store.DeadLetters(ctx, group)
+		assert.Equal(t, len(letters), 0, name)
+
+		// 上下文
+		key := group + "|ctx"
+		_, err = store.GetContext(ctx, key)
+		assert.Equal(t, errors.Is(err, ErrNil), true, name)
+		ttl, _ := store.ContextTTL(ctx, key)
+		assert.Equal(t, ttl, time.Duration(-2), name)
+
+		assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+		body, err := store.GetContext(ctx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, string(body), "body", name)
+		ttl, _ = store.ContextTTL(ctx, key)
+		assert.Equal(t, ttl > 0, true, name)
+
+		assert.NoError(t, store.DelContext(ctx, key))
+		_, err = store.GetContext(ctx, key)
+		assert.Equal(t, errors.Is(err, ErrNil), true, name)
+
+		// master
+		_, err = store.GetMaster(ctx, group)
+		assert.Equal(t, errors.Is(err, ErrNil), true, name)
+		assert.NoError(t, store.SetMaster(ctx, group, "uid1", time.Minute))
+		master, err := store.GetMaster(ctx, group)
+		assert.NoError(t, err)
+		assert.Equal(t, master, "uid1", name)
+
+		// 选举
+		lock1, lock2 := store.NewLocker(), store.NewLocker()
+		success, _ := lock1.Lock(group)
+		assert.Equal(t, success, true, name)
+		success, _ = lock2.Lock(group)
+		assert.Equal(t, success, false, name)
+		lock1.Unlock()
+	}
+}
+
+// 测试 redis key 使用 hash tag，同一个 group 位于同一个 slot
+func TestRedisStoreKeys(t *testing.T) {
+	cli := testdata.NewTestRedis()
+	store := NewRedisStore(cli)
+	ctx := context.TODO()
+	group := "test_store_keys"
+	key := group + "|test_method|tag1"
+
+	assert.NoError(t, store.Heartbeat(ctx, group, "uid1", 100))
+	assert.NoError(t, store.AddWatch(ctx, group, key, "uid1", time.Now().Unix()))
+	assert.NoError(t, store.SetLease(ctx, group, key, time.Now()))
+	assigned, err := store.Assign(ctx, group, key, "uid1", "uid2")
+	assert.Equal(t, []any{assigned, err}, []any{true, nil})
+	assert.NoError(t, store.SetMaster(ctx, group, "uid1", time.Minute))
+	assert.NoError(t, store.SetContext(ctx, key, []byte("body"), time.Minute))
+	assert.NoError(t, store.SetStep(ctx, key, "step1", []byte("done"), time.Minute))
+
+	keys, err := cli.Keys(ctx, "*").Result()
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, keys, []string{
+		"{test_store_keys}:List",
+		"{test_store_keys}:WatchList",
+		"{test_store_keys}:WatchTime",
+		"{test_store_keys}:Lease",
+		"{test_store_keys}:Assign:uid2",
+		"{test_store_keys}:Master",
+		"{test_store_keys}|test_method|tag1",
+		"{test_store_keys}|test_method|tag1:Steps",
+	})
+}
+
+// 测试基于 memory 存储的任务重入
+func TestMonitorMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	group := "test_monitor_memory"
+	done := make(chan string, 1)
+
+	newMonitor := func() Monitor {
+		m := NewMonitorWithStore(store,
+			WithHeartbeatTime(time.Millisecond*10),
+			WithHeartbeatTimeout(time.Second),
+			WithWatchTimeout(time.Minute),
+		)
+		m.Register("test_method", func(mctx MonitorContext) {
+			body, err := mctx.Get()
+			assert.NoError(t, err)
+			done <- string(body)
+		})
+		m.Start(group)
+		return m
+	}
+
+	m1 := newMonitor()
+	defer m1.Stop()
+	time.Sleep(time.Millisecond * 5) // 等待 m1 选举完成
+	assert.Equal(t, m1.IsMaster(), true)
+
+	m2 := newMonitor()
+	time.Sleep(time.Millisecond * 5)
+	assert.Equal(t, m2.IsMaster(), false)
+
+	_, err := m2.Watch("test_method", "1", []byte("m2_1"))
+	assert.NoError(t, err)
+	m2.Stop() // 主动退出，由 m1 重入
+
+	select {
+	case body := <-done:
+		assert.Equal(t, body, "m2_1")
+	case <-time.After(time.Second):
+		t.Fatal("reentry timeout")
+	}
+
+	time.Sleep(time.Millisecond * 20) // 等待 Unwatch
+	keys, err := m1.WatchList()
+	assert.NoError(t, err)
+	assert.Equal(t, len(keys), 0)
+}
+
+// miniredis v2.5 不支持 pub/sub，设置 MONITOR_TEST_REDIS 为 redis 地址后执行
+func TestRedisStoreSubscribeCancel(t *testing.T) {
+	addr := os.Getenv("MONITOR_TEST_REDIS")
+	if addr == "" {
+		t.Skip("MONITOR_TEST_REDIS is not set")
+	}
+	cli := redis.NewClient(&redis.Options{Addr: addr})
+	defer cli.Close()
+	store := NewPrefixStore(NewRedisStore(cli), "test:")
+	group := "test_redis_subscribe_cancel"
+	ctx := context.TODO()
+
+	subCtx, subCancel := context.WithCancel(ctx)
+	received := make(chan string, 1)
+	done, err := store.SubscribeCancel(subCtx, group, func(key string) { received <- key })
+	assert.NoError(t, err)
+	assert.NoError(t, store.PublishCancel(ctx, group, "key1"))
+	select {
+	case key := <-received:
+		assert.Equal(t, key, "key1")
+	case <-time.After(time.Second):
+		t.Fatal("subscribe timeout")
+	}
+
+	subCancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("unsubscribe timeout")
+	}
+	assert.NoError(t, store.PublishCancel(ctx, group, "key2"))
+	select {
+	case key := <-received:
+		t.Fatal("received after unsubscribe", key)
+	case <-time.After(time.Millisecond * 50):
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"time"
 
+	"github.com/FredyXue/go-utils"
 	"github.com/hashicorp/go-multierror"
 	"github.com/pkg/errors"
 )
@@ -54,6 +55,13 @@ func WithExpiredDuration(expiredDuration time.Duration) Option {
 	}
 }
 
+// WithClock 时钟，默认系统时钟。单元测试中可以注入可控的时钟，避免真实等待
+func WithClock(clock utils.Clock) Option {
+	return func(o *retry) {
+		o.clock = clock
+	}
+}
+
 // WithLogMode 是否输出错误日志
 func WithLogMode(logMode bool) Option {
 	return func(o *retry) {
@@ -90,6 +98,7 @@ type retry struct {
 	expiredDuration time.Duration // 最大重试持续时间
 	delay           int           // 是否延迟执行;  0 立即执行  1 一个周期后执行.   默认立即执行
 	logMode         bool          // 是否输出错误日志
+	clock           utils.Clock   // 时钟
 }
 
 // NewRetry .
@@ -102,6 +111,7 @@ func NewRetry(opts ...Option) Retry {
 		expiredDuration: 0,                     // 默认不设置最大重试持续时间
 		delay:           0,
 		logMode:         true, // 默认输出错误日志
+		clock:           utils.RealClock,
 	}
 	for _, o := range opts {
 		o(r)
@@ -112,7 +122,7 @@ func NewRetry(opts ...Option) Retry {
 // Do exec fn
 func (r *retry) Do(fn func() error) error {
 	var gerr error
-	startAt := time.Now()
+	startAt := r.clock.Now()
 	for i := 0; i <= r.retries; i++ {
 		// call backoff first. Someone may want an initial start delay
 		t, berr := r.backoff(i+r.delay, r.interval)
@@ -123,9 +133,9 @@ func (r *retry) Do(fn func() error) error {
 		// 0 duration not sleep
 		if t > 0 {
 			if r.maxInterval > 0 && r.maxInterval < t { // 判断最大超时时间
-				time.Sleep(r.maxInterval)
+				r.clock.Sleep(r.maxInterval)
 			} else {
-				time.Sleep(t)
+				r.clock.Sleep(t)
 			}
 		}
 
@@ -146,7 +156,7 @@ func (r *retry) Do(fn func() error) error {
 
 		// 判断最大重试持续时间
 		// 超时则退出     now - start_at > expired_duration
-		if r.expiredDuration > 0 && time.Since(startAt) > r.expiredDuration {
+		if r.expiredDuration > 0 && r.clock.Since(startAt) > r.expiredDuration {
 			gerr = multierror.Append(gerr, ferr)
 			break
 		}
@@ -177,6 +187,7 @@ func NewPolling(opts ...Option) Polling {
 		expiredDuration: 0,                // 默认不设置最大重试持续时间
 		delay:           0,
 		logMode:         true, // 默认输出错误日志
+		clock:           utils.RealClock,
 	}
 	for _, o := range opts {
 		o(r)
@@ -187,7 +198,7 @@ func NewPolling(opts ...Option) Polling {
 // 轮询 check 的场景
 func (r *retry) Polling(fn func() (bool, error)) (bool, error) {
 	var gerr error
-	startAt := time.Now()
+	startAt := r.clock.Now()
 	for i := 0; i <= r.retries; i++ {
 		// call backoff first. Someone may want an initial start delay
 		t, berr := r.backoff(i+r.delay, r.interval)
@@ -198,9 +209,9 @@ func (r *retry) Polling(fn func() (bool, error)) (bool, error) {
 		// 0 duration not sleep
 		if t > 0 {
 			if r.maxInterval > 0 && r.maxInterval < t { // 判断最大超时时间
-				time.Sleep(r.maxInterval)
+				r.clock.Sleep(r.maxInterval)
 			} else {
-				time.Sleep(t)
+				r.clock.Sleep(t)
 			}
 		}
 
@@ -224,7 +235,7 @@ func (r *retry) Polling(fn func() (bool, error)) (bool, error) {
 
 		// 判断最大重试持续时间
 		// 超时则退出     now - start_at > expired_duration
-		if r.expiredDuration > 0 && time.Since(startAt) > r.expiredDuration {
+		if r.expiredDuration > 0 && r.clock.Since(startAt) > r.expiredDuration {
 			gerr = multierror.Append(gerr, ferr)
 			break
 		}
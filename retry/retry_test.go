@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/FredyXue/go-utils/testdata"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -271,3 +272,59 @@ func TestRetryPolling(t *testing.T) {
 	assert.Equal(t, success, false)
 	assert.Equal(t, err != nil, true)
 }
+
+// 测试使用 FakeClock 时按模拟时间计算间隔与最大时长，不真实等待
+func TestRetryClock(t *testing.T) {
+	clock := testdata.NewFakeClock(time.Now())
+	realStart := time.Now()
+	var times []time.Duration
+	start := clock.Now()
+	record := func() {
+		times = append(times, clock.Since(start))
+	}
+
+	// fibonacci，间隔为分钟级
+	err := NewRetry(
+		WithClock(clock),
+		WithRetry(5),
+		WithInterval(time.Minute),
+		WithLogMode(false),
+	).Do(func() error {
+		record()
+		return errors.New("testerror")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, times, []time.Duration{0, time.Minute, time.Minute * 3, time.Minute * 6, time.Minute * 11, time.Minute * 19}) // 间隔 0、1、2、3、5、8 分钟
+
+	// 最大时长按模拟时间判断，耗时超过 expiredDuration 后退出
+	times, start = nil, clock.Now()
+	err = NewRetry(
+		WithClock(clock),
+		WithRetry(10),
+		WithInterval(time.Minute),
+		WithExpiredDuration(time.Minute*5),
+		WithBackoff(AverageBackOff),
+		WithLogMode(false),
+	).Do(func() error {
+		record()
+		return errors.New("testerror")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, len(times), 7) // 第 7 次执行时已超过 5 分钟
+
+	// polling
+	count := 0
+	start = clock.Now()
+	success, err := NewPolling(
+		WithClock(clock),
+		WithRetry(3),
+		WithInterval(time.Hour),
+		WithBackoff(AverageBackOff),
+		WithLogMode(false),
+	).Polling(func() (bool, error) {
+		count++
+		return false, nil
+	})
+	assert.Equal(t, []any{success, err != nil, count, clock.Since(start)}, []any{false, true, 4, time.Hour * 3})
+	assert.Equal(t, time.Since(realStart) < time.Second, true)
+}
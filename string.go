@@ -7,15 +7,41 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 // Rand custom rand
+// 底层 Source 加锁，可以在多个 goroutine 中并发使用（Read 除外）
 var Rand *rand.Rand
 
 func init() {
 	seed := (time.Now().Unix() + int64(rand.Int31())) / 2
-	Rand = rand.New(rand.NewSource(seed))
+	Rand = rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// lockedSource 并发安全的 rand.Source
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
 }
 
 // RandString 生成指定位数的随机字符串
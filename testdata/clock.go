@@ -0,0 +1,133 @@
+package testdata
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/FredyXue/go-utils"
+)
+
+// FakeClock 可控的时钟，只在 Advance 或 Sleep 时推进
+// Sleep 直接推进时间并立即返回，适用于 retry 等在当前 goroutine 中等待的场景
+// Ticker 在 Advance 时触发，与 time.Ticker 一致，接收方来不及处理时丢弃多余的 tick
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	ch     chan time.Time
+	period time.Duration
+	next   time.Time
+}
+
+// NewFakeClock start 为初始时间
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Sleep 推进 d，不阻塞
+func (c *FakeClock) Sleep(d time.Duration) {
+	c.Advance(d)
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) utils.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTicker{clock: c, ch: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance 推进时间，按时间顺序触发到期的 Ticker
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	end := c.now.Add(d)
+	for {
+		// 找到最早到期的 Ticker
+		var next *fakeTicker
+		for _, t := range c.tickers {
+			if !t.next.After(end) && (next == nil || t.next.Before(next.next)) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		c.now = next.next
+		select {
+		case next.ch <- c.now:
+		default: // 丢弃
+		}
+		next.next = next.next.Add(next.period)
+	}
+	c.now = end
+}
+
+// Step 分多次推进，每次推进 step 后让出调度，直到被触发的 tick 被接收，不真实等待
+// 用于由 Ticker 驱动的后台循环，例如 monitor 心跳与 Locker 续约。没有接收方的 Ticker 最多等待 1 秒
+func (c *FakeClock) Step(d time.Duration, step time.Duration) {
+	for d > 0 {
+		if step > d {
+			step = d
+		}
+		c.Advance(step)
+		d -= step
+		deadline := time.Now().Add(time.Second)
+		for c.pending() && time.Now().Before(deadline) {
+			runtime.Gosched()
+		}
+	}
+}
+
+// pending 是否有未被接收的 tick
+func (c *FakeClock) pending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, t := range c.tickers {
+		if len(t.ch) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Tickers 未停止的 Ticker 数量，用于等待后台循环启动
+func (c *FakeClock) Tickers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tickers)
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *fakeTicker) Stop() {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, v := range c.tickers {
+		if v == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}